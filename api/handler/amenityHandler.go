@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"errors"
+	"golang-test/api/response"
+	"golang-test/cache"
+	"golang-test/models"
+	"golang-test/utils"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type AmenityHandler struct {
+	DB    *gorm.DB
+	Cache cache.Cache
+}
+
+func (a *AmenityHandler) CreateAmenity(c *gin.Context) {
+	var reqBody struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.BindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	amenity := models.Amenity{Name: strings.ToLower(strings.TrimSpace(reqBody.Name))}
+	if amenity.Name == "" || strings.Contains(amenity.Name, ",") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid amenity name"})
+		return
+	}
+	// check if amenity exist
+	var count int64
+	a.DB.Model(models.Amenity{}).Where("name = ?", amenity.Name).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "amenity already exist"})
+		return
+	}
+	if err := a.DB.Create(&amenity).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create amenity"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "amenity created successfully", "data": response.NewAmenity(amenity)})
+}
+
+func (a *AmenityHandler) GetAmenities(c *gin.Context) {
+	var amenities []models.Amenity
+	if err := a.DB.Model(models.Amenity{}).Order("name").Find(&amenities).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch amenities"})
+		return
+	}
+	utils.JSONWithETag(c, gin.H{"amenities": response.NewAmenities(amenities)})
+}
+
+func (a *AmenityHandler) UpdateAmenity(c *gin.Context) {
+	var reqBody struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.BindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	name := strings.ToLower(strings.TrimSpace(reqBody.Name))
+	if name == "" || strings.Contains(name, ",") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid amenity name"})
+		return
+	}
+
+	amenity, ok := a.findAmenity(c)
+	if !ok {
+		return
+	}
+	var count int64
+	a.DB.Model(models.Amenity{}).Where("name = ? AND id <> ?", name, amenity.ID).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "amenity already exist"})
+		return
+	}
+	err := a.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&amenity).Update("name", name).Error; err != nil {
+			return err
+		}
+		return touchAmenityProperties(tx, amenity.ID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update amenity"})
+		return
+	}
+	amenity.Name = name
+	// Listings embed the amenity names of every property
+	invalidateCache(a.Cache, propertiesTag)
+	c.JSON(http.StatusOK, gin.H{"message": "amenity updated successfully", "data": response.NewAmenity(amenity)})
+}
+
+// DeleteAmenity removes the amenity and detaches it from every property
+func (a *AmenityHandler) DeleteAmenity(c *gin.Context) {
+	amenity, ok := a.findAmenity(c)
+	if !ok {
+		return
+	}
+	err := a.DB.Transaction(func(tx *gorm.DB) error {
+		if err := touchAmenityProperties(tx, amenity.ID); err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM property_amenities WHERE amenity_id = ?", amenity.ID).Error; err != nil {
+			return err
+		}
+		// Deleted permanently so the name can be used again
+		return tx.Unscoped().Delete(&amenity).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete amenity"})
+		return
+	}
+	invalidateCache(a.Cache, propertiesTag)
+	c.JSON(http.StatusOK, gin.H{"message": "amenity deleted successfully"})
+}
+
+func (a *AmenityHandler) findAmenity(c *gin.Context) (models.Amenity, bool) {
+	var amenity models.Amenity
+	if err := a.DB.First(&amenity, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "amenity not found"})
+			return amenity, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch amenity"})
+		return amenity, false
+	}
+	return amenity, true
+}
+
+// touchAmenityProperties bumps updated_at of the properties with the amenity, which embed
+// its name, so their ETags change
+func touchAmenityProperties(tx *gorm.DB, amenityID uint) error {
+	return tx.Exec("UPDATE properties SET updated_at = ? WHERE id IN (SELECT property_id FROM property_amenities WHERE amenity_id = ?)", time.Now(), amenityID).Error
+}
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"golang-test/models"
 	"golang-test/utils"
 	"net/http"
@@ -11,13 +12,17 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type AuthHandler struct {
 	DB *gorm.DB
 }
 
-var secretKey = []byte("secret-key")
+const (
+	accessTokenTTL  = time.Hour * 1
+	refreshTokenTTL = time.Hour * 24 * 30
+)
 
 func (h *AuthHandler) Login(c *gin.Context) {
 	type reqBodyFields struct {
@@ -43,23 +48,223 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Email or password is invalid"})
 		return
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256,
-		jwt.MapClaims{
-			"userId": user.ID,
-			"roleId": user.RoleID,
-			"exp":    time.Now().Add(time.Hour * 1).Unix(),
-		})
 
-	tokenString, err := token.SignedString(secretKey)
+	// Every login starts a new session (refresh token family)
+	familyID, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "An error has occurred while generating auth creds"})
+		return
+	}
+	tokenString, refreshToken, err := h.issueTokens(h.DB, &user, familyID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "An error has occurred while generating auth creds"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"token": tokenString,
-		"id":    user.ID,
-		"name":  user.Name,
-		"email": user.Email,
+		"token":        tokenString,
+		"refreshToken": refreshToken,
+		"expiresIn":    int(accessTokenTTL.Seconds()),
+		"id":           user.ID,
+		"name":         user.Name,
+		"email":        user.Email,
+	})
+}
+
+// Refresh exchanges a refresh token for a new access token and a rotated refresh token
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var reqBody struct {
+		RefreshToken string `json:"refreshToken" binding:"required"`
+	}
+	if err := c.BindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	var tokenString, refreshToken string
+	reused := false
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		var stored models.RefreshToken
+		if err := tx.Where("token_hash = ?", utils.HashToken(reqBody.RefreshToken)).First(&stored).Error; err != nil {
+			return errInvalidRefreshToken
+		}
+
+		// A revoked token being presented again means it was leaked, so end the whole session
+		if stored.RevokedAt != nil {
+			reused = true
+			return h.revokeSessions(tx, "family_id = ?", stored.FamilyID)
+		}
+		if time.Now().After(stored.ExpiresAt) {
+			return errInvalidRefreshToken
+		}
+
+		// Only one request can rotate the token, a concurrent one finds it revoked and is
+		// treated as reuse
+		now := time.Now()
+		result := tx.Model(&models.RefreshToken{}).Where("id = ? AND revoked_at IS NULL", stored.ID).Update("revoked_at", &now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			reused = true
+			return h.revokeSessions(tx, "family_id = ?", stored.FamilyID)
+		}
+
+		var user models.User
+		if err := tx.First(&user, stored.UserID).Error; err != nil {
+			return errInvalidRefreshToken
+		}
+
+		var err error
+		tokenString, refreshToken, err = h.issueTokens(tx, &user, stored.FamilyID)
+		return err
+	})
+	if errors.Is(err, errInvalidRefreshToken) || (err == nil && reused) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "An error has occurred while generating auth creds"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":        tokenString,
+		"refreshToken": refreshToken,
+		"expiresIn":    int(accessTokenTTL.Seconds()),
+	})
+}
+
+// Logout revokes the current access token and the refresh tokens of its session
+func (h *AuthHandler) Logout(c *gin.Context) {
+	jti := c.GetString("jti")
+	sessionID := c.GetString("sessionId")
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := h.revokeAccessToken(tx, jti); err != nil {
+			return err
+		}
+		if sessionID == "" {
+			return nil
+		}
+		return h.revokeSessions(tx, "family_id = ?", sessionID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// LogoutAll revokes every session of the current user on all devices
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	userID, exists := c.Get("userId")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	jti := c.GetString("jti")
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := h.revokeAccessToken(tx, jti); err != nil {
+			return err
+		}
+		return h.revokeSessions(tx, "user_id = ?", userID)
 	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out from all devices successfully"})
+}
+
+// JWKS publishes the public keys used to verify access tokens
+func (h *AuthHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, utils.JWKS())
+}
+
+// IsTokenRevoked checks the access token denylist
+func (h *AuthHandler) IsTokenRevoked(jti string) bool {
+	var count int64
+	if err := h.DB.Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error; err != nil {
+		// Fail closed, a token we cannot check is treated as revoked
+		return true
+	}
+	return count > 0
+}
+
+var errInvalidRefreshToken = errors.New("invalid refresh token")
+
+// issueTokens signs a new access token and stores a new refresh token for the given session
+func (h *AuthHandler) issueTokens(db *gorm.DB, user *models.User, familyID string) (string, string, error) {
+	jti, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return "", "", err
+	}
+	tokenString, err := utils.SignToken(jwt.MapClaims{
+		"userId": user.ID,
+		"roleId": user.RoleID,
+		"jti":    jti,
+		"sid":    familyID,
+		"exp":    time.Now().Add(accessTokenTTL).Unix(),
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	refreshToken, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	stored := models.RefreshToken{
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(refreshToken),
+		FamilyID:  familyID,
+		AccessJTI: jti,
+		ExpiresAt: time.Now().Add(refreshTokenTTL),
+	}
+	if err := db.Create(&stored).Error; err != nil {
+		return "", "", err
+	}
+
+	return tokenString, refreshToken, nil
+}
+
+// revokeAccessToken adds a jti to the denylist and drops entries that have already expired
+func (h *AuthHandler) revokeAccessToken(db *gorm.DB, jti string) error {
+	if jti == "" {
+		return nil
+	}
+	if err := db.Unscoped().Where("expires_at < ?", time.Now()).Delete(&models.RevokedToken{}).Error; err != nil {
+		return err
+	}
+	// Revoking a token twice is not an error
+	return db.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "jti"}}, DoNothing: true}).Create(&models.RevokedToken{
+		JTI:       jti,
+		ExpiresAt: time.Now().Add(accessTokenTTL),
+	}).Error
+}
+
+// revokeSessions revokes the refresh tokens matching the query and denylists the
+// access tokens that were issued alongside them
+func (h *AuthHandler) revokeSessions(db *gorm.DB, query string, args ...interface{}) error {
+	var tokens []models.RefreshToken
+	if err := db.Model(&models.RefreshToken{}).Where(query, args...).Where("expires_at > ?", time.Now()).Find(&tokens).Error; err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, token := range tokens {
+		if token.RevokedAt == nil {
+			if err := db.Model(&token).Update("revoked_at", &now).Error; err != nil {
+				return err
+			}
+		}
+		if err := h.revokeAccessToken(db, token.AccessJTI); err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"golang-test/models"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// idempotencyKeyTTL is how long a response is replayed for retries
+	idempotencyKeyTTL = 24 * time.Hour
+	// defaultIdempotencyReservationTimeout is used when ReservationTimeout isn't set
+	defaultIdempotencyReservationTimeout = 5 * time.Minute
+	maxIdempotencyKeyLength              = 255
+)
+
+type IdempotencyHandler struct {
+	DB *gorm.DB
+	// ReservationTimeout is how long a key stays reserved once the server handling its
+	// request stopped refreshing the reservation, after that the request is taken to have
+	// died with its server and the key is freed
+	ReservationTimeout time.Duration
+}
+
+func (h *IdempotencyHandler) reservationTimeout() time.Duration {
+	if h.ReservationTimeout <= 0 {
+		return defaultIdempotencyReservationTimeout
+	}
+	return h.ReservationTimeout
+}
+
+// idempotencyRecorder keeps a copy of the response body
+type idempotencyRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *idempotencyRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *idempotencyRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotent stores the response to requests sent with an Idempotency-Key header and
+// replays it when the request is retried with the same key, so retries don't repeat the
+// request. Keys are scoped to the user and can't be reused for a different request.
+// Server errors aren't stored, the request can be retried with the same key after one.
+// The reservation of a key is refreshed while its request is handled, a key that wasn't
+// refreshed for ReservationTimeout is given up as well. It must run after AuthMiddleware.
+func (h *IdempotencyHandler) Idempotent() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+			return
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256(append([]byte(c.Request.Method+" "+c.Request.URL.Path+"\n"), body...))
+		hash := hex.EncodeToString(sum[:])
+		userID := c.GetUint("userId")
+
+		stored, reservationID, err := h.reserveIdempotencyKey(userID, key, hash)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to check Idempotency-Key"})
+			return
+		}
+		if stored != nil {
+			switch {
+			case stored.RequestHash != hash:
+				c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used for a different request"})
+			case stored.StatusCode == 0:
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is still being processed"})
+			default:
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(stored.StatusCode, "application/json; charset=utf-8", stored.Response)
+				c.Abort()
+			}
+			return
+		}
+
+		// The key is released unless the response is stored, also when the handler panics.
+		// Only this request's reservation is touched, it may have been given up and taken
+		// over by a retry meanwhile.
+		defer func() {
+			if stored == nil {
+				if err := h.DB.Unscoped().Where("id = ? AND status_code = 0", reservationID).Delete(&models.IdempotencyKey{}).Error; err != nil {
+					log.Printf("Failed to release Idempotency-Key: %v", err)
+				}
+			}
+		}()
+
+		// However long the handler takes, its request isn't taken for a dead one and run
+		// again by a retry
+		done := make(chan struct{})
+		go h.keepReserved(reservationID, done)
+		defer close(done)
+
+		recorder := &idempotencyRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		if recorder.Status() >= http.StatusInternalServerError {
+			return
+		}
+		stored = &models.IdempotencyKey{StatusCode: recorder.Status(), Response: recorder.body.Bytes()}
+		if err := h.DB.Model(&models.IdempotencyKey{}).
+			Where("id = ? AND status_code = 0", reservationID).
+			Updates(stored).Error; err != nil {
+			log.Printf("Failed to store response for Idempotency-Key: %v", err)
+			stored = nil
+		}
+	}
+}
+
+// keepReserved refreshes the reservation until done is closed
+func (h *IdempotencyHandler) keepReserved(reservationID uint, done <-chan struct{}) {
+	ticker := time.NewTicker(h.reservationTimeout() / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := h.DB.Model(&models.IdempotencyKey{}).
+				Where("id = ? AND status_code = 0", reservationID).
+				Update("updated_at", time.Now()).Error; err != nil {
+				log.Printf("Failed to refresh Idempotency-Key reservation: %v", err)
+			}
+		}
+	}
+}
+
+// reserveIdempotencyKey records the key as being processed and returns the id of the
+// reservation. If it was used before the stored record is returned instead.
+func (h *IdempotencyHandler) reserveIdempotencyKey(userID uint, key, hash string) (*models.IdempotencyKey, uint, error) {
+	// Expired keys and reservations whose request never finished are removed first
+	now := time.Now()
+	if err := h.DB.Unscoped().
+		Where("expires_at < ? OR (status_code = 0 AND updated_at < ?)", now, now.Add(-h.reservationTimeout())).
+		Delete(&models.IdempotencyKey{}).Error; err != nil {
+		return nil, 0, err
+	}
+	record := models.IdempotencyKey{
+		UserID:      userID,
+		Key:         key,
+		RequestHash: hash,
+		ExpiresAt:   now.Add(idempotencyKeyTTL),
+	}
+	result := h.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil, record.ID, nil
+	}
+	var stored models.IdempotencyKey
+	if err := h.DB.Where("user_id = ? AND key = ?", userID, key).First(&stored).Error; err != nil {
+		return nil, 0, err
+	}
+	return &stored, 0, nil
+}
@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang-test/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newIdempotencyDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.IdempotencyKey{}); err != nil {
+		t.Fatal(err)
+	}
+	// Every connection would get its own in-memory database
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	return db
+}
+
+// idempotentRouter answers with the status returned by respond and counts the requests
+// that reached the handler
+func idempotentRouter(db *gorm.DB, calls *int, respond func() int) *gin.Engine {
+	return idempotentRouterWith(&IdempotencyHandler{DB: db}, calls, respond)
+}
+
+func idempotentRouterWith(h *IdempotencyHandler, calls *int, respond func() int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("userId", uint(1)) })
+	router.POST("/payments", h.Idempotent(), func(c *gin.Context) {
+		*calls++
+		c.JSON(respond(), gin.H{"call": *calls})
+	})
+	return router
+}
+
+func sendIdempotent(router *gin.Engine, key, body string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader(body))
+	request.Header.Set("Idempotency-Key", key)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestIdempotentReplaysResponse(t *testing.T) {
+	var calls int
+	router := idempotentRouter(newIdempotencyDB(t), &calls, func() int { return http.StatusCreated })
+
+	first := sendIdempotent(router, "key-1", `{"amount":100}`)
+	second := sendIdempotent(router, "key-1", `{"amount":100}`)
+	if calls != 1 {
+		t.Fatalf("the handler ran %d times, want once", calls)
+	}
+	if second.Code != http.StatusCreated || second.Body.String() != first.Body.String() {
+		t.Fatalf("got %d %s, want the first response %s", second.Code, second.Body, first.Body)
+	}
+	if second.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatal("the replayed response isn't marked")
+	}
+
+	// Another key is another request
+	if sendIdempotent(router, "key-2", `{"amount":100}`); calls != 2 {
+		t.Fatalf("the handler ran %d times, want twice", calls)
+	}
+}
+
+func TestIdempotentRejectsDifferentRequest(t *testing.T) {
+	var calls int
+	router := idempotentRouter(newIdempotencyDB(t), &calls, func() int { return http.StatusCreated })
+
+	sendIdempotent(router, "key-1", `{"amount":100}`)
+	response := sendIdempotent(router, "key-1", `{"amount":200}`)
+	if response.Code != http.StatusUnprocessableEntity || calls != 1 {
+		t.Fatalf("got %d after %d calls, want 422 without running the handler", response.Code, calls)
+	}
+}
+
+func TestIdempotentReleasesKeyAfterServerError(t *testing.T) {
+	var calls int
+	status := http.StatusInternalServerError
+	db := newIdempotencyDB(t)
+	router := idempotentRouter(db, &calls, func() int { return status })
+
+	if response := sendIdempotent(router, "key-1", `{"amount":100}`); response.Code != http.StatusInternalServerError {
+		t.Fatalf("got %d, want 500", response.Code)
+	}
+	var count int64
+	db.Model(&models.IdempotencyKey{}).Count(&count)
+	if count != 0 {
+		t.Fatal("the key is still reserved after a server error")
+	}
+
+	status = http.StatusCreated
+	response := sendIdempotent(router, "key-1", `{"amount":100}`)
+	if response.Code != http.StatusCreated || calls != 2 {
+		t.Fatalf("got %d after %d calls, want the retry to run the handler", response.Code, calls)
+	}
+}
+
+func TestIdempotentGivesUpAbandonedReservation(t *testing.T) {
+	var calls int
+	db := newIdempotencyDB(t)
+	router := idempotentRouter(db, &calls, func() int { return http.StatusCreated })
+
+	// Reserve the key the way a request that is still running does
+	sendIdempotent(router, "key-1", `{"amount":100}`)
+	db.Model(&models.IdempotencyKey{}).Where("key = ?", "key-1").Update("status_code", 0)
+	if response := sendIdempotent(router, "key-1", `{"amount":100}`); response.Code != http.StatusConflict {
+		t.Fatalf("got %d, want 409 while the first request is running", response.Code)
+	}
+
+	// The server handling it died and stopped refreshing the reservation, after the
+	// timeout a retry runs the request
+	db.Model(&models.IdempotencyKey{}).Where("key = ?", "key-1").
+		Update("updated_at", time.Now().Add(-defaultIdempotencyReservationTimeout-time.Second))
+	response := sendIdempotent(router, "key-1", `{"amount":100}`)
+	if response.Code != http.StatusCreated || calls != 2 {
+		t.Fatalf("got %d after %d calls, want the retry to run the handler", response.Code, calls)
+	}
+	if replayed := sendIdempotent(router, "key-1", `{"amount":100}`); replayed.Body.String() != response.Body.String() || calls != 2 {
+		t.Fatalf("got %s, want the retry's response replayed", replayed.Body)
+	}
+}
+
+func TestIdempotentKeepsSlowRequestsReserved(t *testing.T) {
+	var calls int
+	db := newIdempotencyDB(t)
+	started, finish := make(chan struct{}), make(chan struct{})
+	router := idempotentRouterWith(&IdempotencyHandler{DB: db, ReservationTimeout: 30 * time.Millisecond}, &calls, func() int {
+		if calls == 1 {
+			close(started)
+			<-finish
+		}
+		return http.StatusCreated
+	})
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- sendIdempotent(router, "key-1", `{"amount":100}`) }()
+	<-started
+
+	// The first request runs for several timeouts, its reservation is refreshed meanwhile
+	time.Sleep(100 * time.Millisecond)
+	if response := sendIdempotent(router, "key-1", `{"amount":100}`); response.Code != http.StatusConflict {
+		t.Fatalf("got %d, want 409 while the slow request is running", response.Code)
+	}
+	close(finish)
+	if response := <-done; response.Code != http.StatusCreated || calls != 1 {
+		t.Fatalf("got %d after %d calls, want the request to run once", response.Code, calls)
+	}
+}
@@ -0,0 +1,293 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"golang-test/api/response"
+	"golang-test/cache"
+	"golang-test/jobs"
+	"golang-test/models"
+	"golang-test/queue"
+	"golang-test/utils"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxLeaseRenewalMonths limits how much a single renewal can extend a lease by
+const maxLeaseRenewalMonths = 60
+
+var (
+	errLeaseForbidden     = errors.New("not a party of the lease")
+	errLeaseNotActive     = errors.New("lease is not active")
+	errLeaseRenewal       = errors.New("lease can't be renewed")
+	errLeaseTermsRequired = errors.New("lease terms are required")
+)
+
+type LeaseHandler struct {
+	DB    *gorm.DB
+	Cache cache.Cache
+	Jobs  queue.Queue
+}
+
+// leaseTerms are agreed on when a rental transaction is completed
+type leaseTerms struct {
+	StartDate *time.Time `json:"startDate" binding:"required"`
+	EndDate   *time.Time `json:"endDate" binding:"required"`
+	// MonthlyRent defaults to the price of the transaction
+	MonthlyRent   *float32 `json:"monthlyRent"`
+	Deposit       float32  `json:"deposit"`
+	RenewalMonths int      `json:"renewalMonths"`
+	MaxRenewals   int      `json:"maxRenewals"`
+}
+
+// validate checks the terms, the lease has to end in the future
+func (t leaseTerms) validate(now time.Time) error {
+	switch {
+	case !t.EndDate.After(*t.StartDate) || !t.EndDate.After(now):
+		return errors.New("endDate must be after startDate and in the future")
+	case t.MonthlyRent != nil && *t.MonthlyRent <= 0:
+		return errors.New("monthlyRent must be positive")
+	case t.Deposit < 0:
+		return errors.New("deposit must not be negative")
+	case t.RenewalMonths < 0 || t.RenewalMonths > maxLeaseRenewalMonths:
+		return errors.New("renewalMonths must be between 0 and 60")
+	case t.MaxRenewals < 0:
+		return errors.New("maxRenewals must not be negative")
+	}
+	return nil
+}
+
+// newLease creates the lease of a completed rental transaction with its rent schedule,
+// and charges the deposit to the tenant
+func newLease(tx *gorm.DB, transaction models.Transaction, terms leaseTerms) (models.Lease, error) {
+	lease := models.Lease{
+		TransactionID: transaction.ID,
+		PropertyID:    transaction.PropertyID,
+		TenantID:      transaction.ClientID,
+		OwnerID:       transaction.OwnerID,
+		StartDate:     *terms.StartDate,
+		EndDate:       *terms.EndDate,
+		MonthlyRent:   transaction.Price,
+		Deposit:       terms.Deposit,
+		RenewalMonths: terms.RenewalMonths,
+		MaxRenewals:   terms.MaxRenewals,
+		Status:        models.LeaseStatusActive,
+	}
+	if terms.MonthlyRent != nil {
+		lease.MonthlyRent = *terms.MonthlyRent
+	}
+	lease.Installments = models.RentSchedule(lease, lease.StartDate)
+	if err := tx.Create(&lease).Error; err != nil {
+		return lease, err
+	}
+	if deposit := models.Cents(lease.Deposit); deposit > 0 {
+		journal := models.NewLedgerJournal(lease.ID, models.LedgerKindDeposit, "security deposit",
+			models.LedgerAccountTenantReceivable, models.LedgerAccountDepositHeld, deposit)
+		if err := models.PostLedgerJournal(tx, &journal); err != nil {
+			return lease, err
+		}
+	}
+	return lease, nil
+}
+
+// GetLeases lists the leases the user is the tenant or owner of, the ones ending first
+// come first
+func (l *LeaseHandler) GetLeases(c *gin.Context) {
+	userID := c.GetUint("userId")
+	query := l.DB.Preload("Property").Where("tenant_id = ? OR owner_id = ?", userID, userID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var leases []models.Lease
+	if err := query.Order("end_date, id").Find(&leases).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch leases"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"leases": response.NewLeases(leases)})
+}
+
+// GetLease returns a lease to its tenant and owner
+func (l *LeaseHandler) GetLease(c *gin.Context) {
+	var lease models.Lease
+	if err := l.DB.Preload("Property").First(&lease, c.Param("id")).Error; err != nil {
+		respondLeaseError(c, err)
+		return
+	}
+	if !isLeaseParty(c, lease) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Lease not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"lease": response.NewLease(lease)})
+}
+
+// RenewLease extends the lease by its renewal term and schedules the rent of the added
+// months, the tenant or owner can renew it until it ends
+func (l *LeaseHandler) RenewLease(c *gin.Context) {
+	var installments []models.RentInstallment
+	lease, err := l.updateLease(c, func(tx *gorm.DB, lease *models.Lease, now time.Time) error {
+		if !lease.Renewable() || !lease.EndDate.After(now) {
+			return errLeaseRenewal
+		}
+		previousEnd := lease.EndDate
+		lease.EndDate = models.AddMonths(lease.EndDate, lease.RenewalMonths)
+		lease.Renewals++
+		if err := tx.Model(lease).Select("end_date", "renewals").Updates(lease).Error; err != nil {
+			return err
+		}
+		if installments = models.RentSchedule(*lease, previousEnd); len(installments) == 0 {
+			return nil
+		}
+		return tx.Create(&installments).Error
+	})
+	if err != nil {
+		respondLeaseError(c, err)
+		return
+	}
+
+	scheduleLeaseEnd(c.Request.Context(), l.Jobs, lease)
+	scheduleRentCharges(c.Request.Context(), l.Jobs, installments)
+	c.JSON(http.StatusOK, gin.H{"message": "Lease renewed", "lease": response.NewLease(lease)})
+}
+
+// TerminateLease ends the lease early, at the given end date or right away. Rent that
+// would be due after the end is cancelled, the property becomes available when the lease
+// ends.
+func (l *LeaseHandler) TerminateLease(c *gin.Context) {
+	var reqBody struct {
+		EndDate *time.Time `json:"endDate"`
+		Reason  string     `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&reqBody); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	userID := c.GetUint("userId")
+	var property models.Property
+	var ended bool
+	lease, err := l.updateLease(c, func(tx *gorm.DB, lease *models.Lease, now time.Time) error {
+		if lease.TerminatedAt != nil {
+			return errLeaseNotActive
+		}
+		endDate := now
+		if reqBody.EndDate != nil {
+			if reqBody.EndDate.Before(now) || reqBody.EndDate.After(lease.EndDate) {
+				return invalidLeaseError{errors.New("endDate must be between now and the current end date")}
+			}
+			endDate = *reqBody.EndDate
+		}
+		lease.EndDate = endDate
+		lease.TerminatedAt = &now
+		lease.TerminatedByID = &userID
+		lease.TerminationReason = reqBody.Reason
+		if err := tx.Model(lease).Select("end_date", "terminated_at", "terminated_by_id", "termination_reason").Updates(lease).Error; err != nil {
+			return err
+		}
+		err := tx.Model(&models.RentInstallment{}).
+			Where("lease_id = ? AND status = ? AND due_date >= ?", lease.ID, models.RentInstallmentStatusScheduled, endDate).
+			Update("status", models.RentInstallmentStatusCancelled).Error
+		if err != nil {
+			return err
+		}
+		if endDate.After(now) {
+			return nil
+		}
+		if property, ended, err = jobs.EndDueLease(tx, lease.ID, now); err != nil {
+			return err
+		}
+		lease.Status = models.LeaseStatusTerminated
+		return nil
+	})
+	if err != nil {
+		respondLeaseError(c, err)
+		return
+	}
+
+	if ended {
+		invalidateCache(l.Cache, propertyTags(property)...)
+	} else {
+		scheduleLeaseEnd(c.Request.Context(), l.Jobs, lease)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Lease terminated", "lease": response.NewLease(lease)})
+}
+
+// updateLease locks the lease and runs update if the authenticated user is a party of it
+// and it is still active
+func (l *LeaseHandler) updateLease(c *gin.Context, update func(tx *gorm.DB, lease *models.Lease, now time.Time) error) (models.Lease, error) {
+	var lease models.Lease
+	err := l.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&lease, c.Param("id")).Error; err != nil {
+			return err
+		}
+		if !isLeaseParty(c, lease) {
+			return errLeaseForbidden
+		}
+		if lease.Status != models.LeaseStatusActive {
+			return errLeaseNotActive
+		}
+		return update(tx, &lease, time.Now())
+	})
+	return lease, err
+}
+
+// isLeaseParty reports whether the authenticated user is the tenant or owner, or may
+// manage any listing
+func isLeaseParty(c *gin.Context, lease models.Lease) bool {
+	userID := c.GetUint("userId")
+	return userID == lease.TenantID || userID == lease.OwnerID || utils.HasPermission(c, models.PermissionPropertyManageAny)
+}
+
+// scheduleLeaseEnd enqueues the job that ends the lease on its end date. Jobs for earlier
+// end dates of the lease find it still running and do nothing. If the job is lost, the
+// worker's sweep of due leases ends the lease a little later.
+func scheduleLeaseEnd(ctx context.Context, q queue.Queue, lease models.Lease) {
+	if q == nil {
+		return
+	}
+	err := queue.Enqueue(ctx, q, jobs.TypeEndLease, jobs.EndLease{LeaseID: lease.ID}, queue.RunAt(lease.EndDate))
+	if err != nil {
+		log.Printf("Failed to schedule end of lease %d: %v", lease.ID, err)
+	}
+}
+
+// scheduleRentCharges enqueues the jobs that charge the installments on their due dates,
+// the worker's sweep of due rent charges installments whose job was lost
+func scheduleRentCharges(ctx context.Context, q queue.Queue, installments []models.RentInstallment) {
+	if q == nil {
+		return
+	}
+	for _, installment := range installments {
+		err := queue.Enqueue(ctx, q, jobs.TypeChargeRent, jobs.ChargeRent{InstallmentID: installment.ID}, queue.RunAt(installment.DueDate))
+		if err != nil {
+			log.Printf("Failed to schedule rent installment %d: %v", installment.ID, err)
+		}
+	}
+}
+
+// invalidLeaseError is input that can only be checked once the lease is loaded
+type invalidLeaseError struct {
+	error
+}
+
+// respondLeaseError responds with the status matching an error of the lease endpoints
+func respondLeaseError(c *gin.Context, err error) {
+	var invalid invalidLeaseError
+	switch {
+	case errors.As(err, &invalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": invalid.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, errLeaseForbidden):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Lease not found"})
+	case errors.Is(err, errLeaseNotActive):
+		c.JSON(http.StatusConflict, gin.H{"error": "Lease is no longer active"})
+	case errors.Is(err, errLeaseRenewal):
+		c.JSON(http.StatusConflict, gin.H{"error": "Lease can't be renewed"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update lease"})
+	}
+}
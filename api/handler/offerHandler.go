@@ -0,0 +1,410 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"golang-test/api/response"
+	"golang-test/cache"
+	"golang-test/jobs"
+	"golang-test/models"
+	"golang-test/queue"
+	"golang-test/utils"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// defaultOfferTTL is how long an offer stays open unless it says otherwise
+	defaultOfferTTL = 72 * time.Hour
+	maxOfferTTL     = 30 * 24 * time.Hour
+)
+
+var (
+	errOfferForbidden      = errors.New("not allowed to answer the offer")
+	errOfferClosed         = errors.New("offer is no longer open")
+	errOfferExpired        = errors.New("offer has expired")
+	errOfferExists         = errors.New("client already has an open offer")
+	errOwnProperty         = errors.New("offer on own property")
+	errPropertyUnavailable = errors.New("property is not available")
+)
+
+type OfferHandler struct {
+	DB    *gorm.DB
+	Cache cache.Cache
+	Jobs  queue.Queue
+}
+
+// offerTerms are the price and terms proposed by an offer or counter-offer
+type offerTerms struct {
+	Price     *float32   `json:"price" binding:"required"`
+	Terms     string     `json:"terms"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+// expiry returns when the offer expires, defaultOfferTTL from now unless it was given
+func (t offerTerms) expiry(now time.Time) (time.Time, error) {
+	if t.ExpiresAt == nil {
+		return now.Add(defaultOfferTTL), nil
+	}
+	if !t.ExpiresAt.After(now) || t.ExpiresAt.After(now.Add(maxOfferTTL)) {
+		return time.Time{}, errors.New("expiresAt must be in the next 30 days")
+	}
+	return *t.ExpiresAt, nil
+}
+
+// invalidOfferError is input that can only be checked once the property is loaded
+type invalidOfferError struct {
+	error
+}
+
+// bindOfferTerms reads the request body into reqBody and validates its terms
+func bindOfferTerms(c *gin.Context, reqBody interface{}, terms *offerTerms) bool {
+	if err := c.ShouldBindJSON(reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return false
+	}
+	if *terms.Price <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "price must be positive"})
+		return false
+	}
+	return true
+}
+
+// CreateOffer proposes a price and terms for an available property
+func (o *OfferHandler) CreateOffer(c *gin.Context) {
+	propertyID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Property not found"})
+		return
+	}
+	var reqBody struct {
+		offerTerms
+		Type string `json:"type"` // Only needed for properties offered both ways
+	}
+	if !bindOfferTerms(c, &reqBody, &reqBody.offerTerms) {
+		return
+	}
+	now := time.Now()
+	expiresAt, err := reqBody.expiry(now)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	clientID := c.GetUint("userId")
+	var offer models.Offer
+	err = o.DB.Transaction(func(tx *gorm.DB) error {
+		// Locked so the offer can't be made while another one is being accepted
+		property, err := lockProperty(tx, uint(propertyID))
+		if err != nil {
+			return err
+		}
+		if property.Status != models.PropertyStatusAvailable {
+			return errPropertyUnavailable
+		}
+		if property.OwnerID == clientID {
+			return errOwnProperty
+		}
+		transactionType, err := chooseTransactionType(property, reqBody.Type)
+		if err != nil {
+			return invalidOfferError{err}
+		}
+		var open int64
+		if err := tx.Model(&models.Offer{}).
+			Where("property_id = ? AND client_id = ? AND status = ? AND expires_at > ?", property.ID, clientID, models.OfferStatusPending, now).
+			Count(&open).Error; err != nil {
+			return err
+		}
+		if open > 0 {
+			return errOfferExists
+		}
+
+		offer = models.Offer{
+			PropertyID:   property.ID,
+			ClientID:     clientID,
+			OwnerID:      property.OwnerID,
+			ProposedByID: clientID,
+			Type:         transactionType,
+			Price:        *reqBody.Price,
+			Terms:        reqBody.Terms,
+			Status:       models.OfferStatusPending,
+			ExpiresAt:    expiresAt,
+		}
+		return tx.Create(&offer).Error
+	})
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Property not found"})
+		return
+	case errors.Is(err, errOwnProperty):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot make an offer on your own property"})
+		return
+	case errors.Is(err, errOfferExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "You already have an open offer on this property"})
+		return
+	case err != nil:
+		respondOfferError(c, err)
+		return
+	}
+
+	o.scheduleExpiry(c.Request.Context(), offer)
+	c.JSON(http.StatusCreated, gin.H{"message": "Offer sent", "offer": response.NewOffer(offer)})
+}
+
+// GetOffers lists the offers the user made or received, newest first
+func (o *OfferHandler) GetOffers(c *gin.Context) {
+	userID := c.GetUint("userId")
+	query := o.DB.Preload("Property").Where("client_id = ? OR owner_id = ?", userID, userID)
+	if propertyID := c.Query("propertyId"); propertyID != "" {
+		id, err := strconv.ParseUint(propertyID, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid propertyId"})
+			return
+		}
+		query = query.Where("property_id = ?", id)
+	}
+	// Pending offers that ran out are listed as expired, like they are shown
+	switch status := c.Query("status"); status {
+	case "":
+	case models.OfferStatusPending:
+		query = query.Where("status = ? AND expires_at > ?", status, time.Now())
+	case models.OfferStatusExpired:
+		query = query.Where("(status = ? OR (status = ? AND expires_at <= ?))", status, models.OfferStatusPending, time.Now())
+	default:
+		query = query.Where("status = ?", status)
+	}
+
+	var offers []models.Offer
+	if err := query.Order("created_at DESC, id DESC").Find(&offers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch offers"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"offers": response.NewOffers(offers)})
+}
+
+// GetOffer returns an offer to either party
+func (o *OfferHandler) GetOffer(c *gin.Context) {
+	var offer models.Offer
+	if err := o.DB.Preload("Property").First(&offer, c.Param("id")).Error; err != nil {
+		respondOfferError(c, err)
+		return
+	}
+	userID := c.GetUint("userId")
+	if userID != offer.ClientID && userID != offer.OwnerID && !utils.HasPermission(c, models.PermissionPropertyManageAny) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Offer not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"offer": response.NewOffer(offer)})
+}
+
+// AcceptOffer accepts the offer and creates the transaction at the agreed price. The
+// property is under offer until the transaction is completed, the other open offers on
+// it are rejected.
+func (o *OfferHandler) AcceptOffer(c *gin.Context) {
+	var transaction models.Transaction
+	var previous models.Property
+	offer, err := o.answerOffer(c, func(tx *gorm.DB, offer *models.Offer, now time.Time) error {
+		property, err := lockProperty(tx, offer.PropertyID)
+		if err != nil {
+			return err
+		}
+		if property.Status != models.PropertyStatusAvailable {
+			return errPropertyUnavailable
+		}
+		previous = property
+
+		offer.Status = models.OfferStatusAccepted
+		offer.RespondedAt = &now
+		if err := tx.Model(offer).Select("status", "responded_at").Updates(offer).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Offer{}).
+			Where("property_id = ? AND status = ? AND id <> ?", offer.PropertyID, models.OfferStatusPending, offer.ID).
+			Updates(map[string]interface{}{"status": models.OfferStatusRejected, "responded_at": now}).Error; err != nil {
+			return err
+		}
+		transaction, err = openTransaction(tx, &property, offer.ClientID, offer.Type, offer.Price, &offer.ID)
+		return err
+	})
+	if err != nil {
+		respondOfferError(c, err)
+		return
+	}
+
+	invalidateCache(o.Cache, propertyTags(previous)...)
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Offer accepted, the property is under offer until the owner completes the transaction",
+		"offer":       response.NewOffer(offer),
+		"transaction": response.NewTransaction(transaction),
+	})
+}
+
+// RejectOffer turns the offer down
+func (o *OfferHandler) RejectOffer(c *gin.Context) {
+	offer, err := o.answerOffer(c, func(tx *gorm.DB, offer *models.Offer, now time.Time) error {
+		offer.Status = models.OfferStatusRejected
+		offer.RespondedAt = &now
+		return tx.Model(offer).Select("status", "responded_at").Updates(offer).Error
+	})
+	if err != nil {
+		respondOfferError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Offer rejected", "offer": response.NewOffer(offer)})
+}
+
+// CounterOffer answers the offer with another price and terms, which the other party
+// can accept, reject or counter in turn
+func (o *OfferHandler) CounterOffer(c *gin.Context) {
+	var reqBody offerTerms
+	if !bindOfferTerms(c, &reqBody, &reqBody) {
+		return
+	}
+	if _, err := reqBody.expiry(time.Now()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var counter models.Offer
+	offer, err := o.answerOffer(c, func(tx *gorm.DB, offer *models.Offer, now time.Time) error {
+		expiresAt, err := reqBody.expiry(now)
+		if err != nil {
+			return invalidOfferError{err}
+		}
+		property, err := lockProperty(tx, offer.PropertyID)
+		if err != nil {
+			return err
+		}
+		if property.Status != models.PropertyStatusAvailable {
+			return errPropertyUnavailable
+		}
+
+		offer.Status = models.OfferStatusCountered
+		offer.RespondedAt = &now
+		if err := tx.Model(offer).Select("status", "responded_at").Updates(offer).Error; err != nil {
+			return err
+		}
+		counter = models.Offer{
+			PropertyID:   offer.PropertyID,
+			ClientID:     offer.ClientID,
+			OwnerID:      offer.OwnerID,
+			ProposedByID: c.GetUint("userId"),
+			CountersID:   &offer.ID,
+			Type:         offer.Type,
+			Price:        *reqBody.Price,
+			Terms:        reqBody.Terms,
+			Status:       models.OfferStatusPending,
+			ExpiresAt:    expiresAt,
+		}
+		return tx.Create(&counter).Error
+	})
+	if err != nil {
+		respondOfferError(c, err)
+		return
+	}
+
+	o.scheduleExpiry(c.Request.Context(), counter)
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Counter-offer sent",
+		"offer":   response.NewOffer(offer),
+		"counter": response.NewOffer(counter),
+	})
+}
+
+// WithdrawOffer takes back an offer that wasn't answered yet, only whoever proposed it
+// can withdraw it
+func (o *OfferHandler) WithdrawOffer(c *gin.Context) {
+	userID := c.GetUint("userId")
+	var offer models.Offer
+	err := o.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&offer, c.Param("id")).Error; err != nil {
+			return err
+		}
+		if offer.ProposedByID != userID {
+			return errOfferForbidden
+		}
+		now := time.Now()
+		if offer.Status != models.OfferStatusPending {
+			return errOfferClosed
+		}
+		if !offer.Open(now) {
+			return errOfferExpired
+		}
+		offer.Status = models.OfferStatusWithdrawn
+		offer.RespondedAt = &now
+		return tx.Model(&offer).Select("status", "responded_at").Updates(&offer).Error
+	})
+	if err != nil {
+		respondOfferError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Offer withdrawn", "offer": response.NewOffer(offer)})
+}
+
+// answerOffer locks the offer and runs answer if the authenticated user has to answer it
+// and it is still open
+func (o *OfferHandler) answerOffer(c *gin.Context, answer func(tx *gorm.DB, offer *models.Offer, now time.Time) error) (models.Offer, error) {
+	userID := c.GetUint("userId")
+	var offer models.Offer
+	err := o.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&offer, c.Param("id")).Error; err != nil {
+			return err
+		}
+		if offer.RespondentID() != userID {
+			if offer.ProposedByID == userID {
+				return errOfferForbidden
+			}
+			return gorm.ErrRecordNotFound
+		}
+		now := time.Now()
+		if offer.Status != models.OfferStatusPending {
+			return errOfferClosed
+		}
+		// The expiry job or sweep may not have marked the offer expired yet
+		if !offer.Open(now) {
+			return errOfferExpired
+		}
+		return answer(tx, &offer, now)
+	})
+	return offer, err
+}
+
+// scheduleExpiry enqueues the job that expires the offer when it wasn't answered in time.
+// If that fails the offer still can't be answered after it expired, it is just shown as
+// pending in the database.
+func (o *OfferHandler) scheduleExpiry(ctx context.Context, offer models.Offer) {
+	if o.Jobs == nil {
+		return
+	}
+	err := queue.Enqueue(ctx, o.Jobs, jobs.TypeExpireOffer, jobs.ExpireOffer{OfferID: offer.ID}, queue.RunAt(offer.ExpiresAt))
+	if err != nil {
+		log.Printf("Failed to schedule expiry of offer %d: %v", offer.ID, err)
+	}
+}
+
+// respondOfferError responds with the status matching an error of the offer endpoints
+func respondOfferError(c *gin.Context, err error) {
+	var invalid invalidOfferError
+	switch {
+	case errors.As(err, &invalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": invalid.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Offer not found"})
+	case errors.Is(err, errOfferForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "The other party has to answer this offer"})
+	case errors.Is(err, errOfferClosed):
+		c.JSON(http.StatusConflict, gin.H{"error": "Offer is no longer open"})
+	case errors.Is(err, errOfferExpired):
+		c.JSON(http.StatusConflict, gin.H{"error": "Offer has expired"})
+	case errors.Is(err, errPropertyUnavailable):
+		c.JSON(http.StatusConflict, gin.H{"error": "Property is not available"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update offer"})
+	}
+}
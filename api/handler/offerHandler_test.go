@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang-test/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestOfferTermsExpiry(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		expiresAt := now.Add(d)
+		return &expiresAt
+	}
+	tests := []struct {
+		name      string
+		expiresAt *time.Time
+		want      time.Time
+		wantErr   bool
+	}{
+		{"default", nil, now.Add(defaultOfferTTL), false},
+		{"in an hour", at(time.Hour), now.Add(time.Hour), false},
+		{"at the maximum", at(maxOfferTTL), now.Add(maxOfferTTL), false},
+		{"after the maximum", at(maxOfferTTL + time.Second), time.Time{}, true},
+		{"now", at(0), time.Time{}, true},
+		{"in the past", at(-time.Hour), time.Time{}, true},
+	}
+	for _, tt := range tests {
+		got, err := offerTerms{ExpiresAt: tt.expiresAt}.expiry(now)
+		if (err != nil) != tt.wantErr || !got.Equal(tt.want) {
+			t.Errorf("%s: got %s, %v, want %s", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+// newOfferDB holds a pending offer on property 1 from client 2 to owner 1 that expired a
+// minute ago, and one that is still open
+func newOfferDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard, DisableForeignKeyConstraintWhenMigrating: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Migrator().CreateTable(&models.Offer{}); err != nil {
+		t.Fatal(err)
+	}
+	createPropertiesTable(t, db)
+	if err := db.Exec("INSERT INTO properties (id, status, version, owner_id) VALUES (1, ?, 1, 1)", models.PropertyStatusAvailable).Error; err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for _, expiresAt := range []time.Time{now.Add(-time.Minute), now.Add(time.Hour)} {
+		offer := models.Offer{PropertyID: 1, ClientID: 2, OwnerID: 1, ProposedByID: 2, Type: models.TransactionTypeBuy,
+			Price: 100, Status: models.OfferStatusPending, ExpiresAt: expiresAt}
+		if err := db.Create(&offer).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+func sendOfferRequest(db *gorm.DB, userID uint, method, path, body string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	handler := &OfferHandler{DB: db}
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("userId", userID) })
+	router.GET("/offers", handler.GetOffers)
+	router.POST("/offers/:id/accept", handler.AcceptOffer)
+	router.POST("/offers/:id/counter", handler.CounterOffer)
+	router.POST("/offers/:id/withdraw", handler.WithdrawOffer)
+
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestExpiredOffersCantBeAnswered(t *testing.T) {
+	db := newOfferDB(t)
+	tests := []struct {
+		name   string
+		userID uint
+		path   string
+		body   string
+	}{
+		{"accept", 1, "/offers/1/accept", ""},
+		{"counter", 1, "/offers/1/counter", `{"price":90}`},
+		{"withdraw", 2, "/offers/1/withdraw", ""},
+	}
+	for _, tt := range tests {
+		response := sendOfferRequest(db, tt.userID, http.MethodPost, tt.path, tt.body)
+		if response.Code != http.StatusConflict || !strings.Contains(response.Body.String(), "expired") {
+			t.Errorf("%s: got %d %s, want 409 for the expired offer", tt.name, response.Code, response.Body)
+		}
+	}
+
+	var offers []models.Offer
+	db.Order("id").Find(&offers)
+	if len(offers) != 2 || offers[0].Status != models.OfferStatusPending || offers[0].RespondedAt != nil {
+		t.Fatalf("the expired offer was changed: %+v", offers)
+	}
+}
+
+func TestGetOffersListsExpiredPendingOffersAsExpired(t *testing.T) {
+	db := newOfferDB(t)
+	for status, wantID := range map[string]uint{models.OfferStatusPending: 2, models.OfferStatusExpired: 1} {
+		response := sendOfferRequest(db, 1, http.MethodGet, "/offers?status="+status, "")
+		var body struct {
+			Offers []struct {
+				ID     uint   `json:"id"`
+				Status string `json:"status"`
+			} `json:"offers"`
+		}
+		if err := json.Unmarshal(response.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: %v in %s", status, err, response.Body)
+		}
+		if len(body.Offers) != 1 || body.Offers[0].ID != wantID || body.Offers[0].Status != status {
+			t.Errorf("status=%s: got %+v, want offer %d", status, body.Offers, wantID)
+		}
+	}
+}
@@ -0,0 +1,359 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"golang-test/api/response"
+	"golang-test/jobs"
+	"golang-test/models"
+	"golang-test/payment"
+	"golang-test/queue"
+	"golang-test/utils"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// settleCheckDelay is when a pending bank transfer is first checked, the worker retries
+// the check with a growing delay. Once the job runs out of attempts the worker's sweep
+// keeps checking the payment until it settles or is given up.
+const (
+	settleCheckDelay    = time.Minute
+	settleCheckAttempts = 20
+)
+
+var (
+	errRefundForbidden = errors.New("only the owner can refund payments")
+	errNotRefundable   = errors.New("amount exceeds what can be refunded")
+	errDepositExceeded = errors.New("amount exceeds the deposit held")
+)
+
+type PaymentHandler struct {
+	DB       *gorm.DB
+	Jobs     queue.Queue
+	Payments payment.PaymentProvider
+	// Currency of the amounts, which are in cents
+	Currency string
+}
+
+// RecordPayment records a payment towards the lease. The tenant pays by card or bank
+// transfer through the payment provider, the owner records payments received otherwise.
+func (p *PaymentHandler) RecordPayment(c *gin.Context) {
+	var reqBody struct {
+		Amount int64  `json:"amount" binding:"required,gt=0"`
+		Method string `json:"method" binding:"required"`
+		Source string `json:"source"`
+	}
+	if err := c.ShouldBindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	lease, ok := p.leaseOf(c)
+	if !ok {
+		return
+	}
+	userID := c.GetUint("userId")
+	switch {
+	case userID == lease.TenantID && (reqBody.Method == payment.MethodCard || reqBody.Method == payment.MethodBank):
+		if reqBody.Source == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "source is required for card and bank payments"})
+			return
+		}
+	case userID == lease.TenantID:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "method must be card or bank"})
+		return
+	case reqBody.Method != models.PaymentMethodManual:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Owners can only record manual payments"})
+		return
+	}
+
+	record := models.Payment{
+		LeaseID:      lease.ID,
+		PayerID:      lease.TenantID,
+		RecordedByID: userID,
+		Amount:       reqBody.Amount,
+		Method:       reqBody.Method,
+		Status:       models.PaymentStatusPending,
+	}
+	if err := p.DB.Create(&record).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record payment"})
+		return
+	}
+
+	result := payment.Result{Status: payment.StatusSucceeded}
+	var chargeErr error
+	if record.Method != models.PaymentMethodManual {
+		result, chargeErr = p.Payments.Charge(c.Request.Context(), payment.ChargeRequest{
+			Amount:         record.Amount,
+			Currency:       p.Currency,
+			Method:         record.Method,
+			Source:         reqBody.Source,
+			Description:    fmt.Sprintf("Rent for lease %d", lease.ID),
+			IdempotencyKey: fmt.Sprintf("payment-%d", record.ID),
+		})
+		if chargeErr != nil {
+			result = payment.Result{Status: payment.StatusFailed, FailureReason: "payment provider error"}
+		}
+	}
+	err := p.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		record, err = jobs.ApplyPaymentResult(tx, record.ID, result, time.Now())
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record payment"})
+		return
+	}
+
+	switch {
+	case chargeErr != nil:
+		log.Printf("Failed to charge payment %d: %v", record.ID, chargeErr)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Payment provider is not available", "payment": response.NewPayment(record)})
+	case record.Status == models.PaymentStatusFailed:
+		c.JSON(http.StatusPaymentRequired, gin.H{"error": "Payment failed: " + record.FailureReason, "payment": response.NewPayment(record)})
+	case record.Status == models.PaymentStatusPending:
+		p.scheduleSettlement(c, record)
+		c.JSON(http.StatusAccepted, gin.H{"message": "Payment is pending until the bank settles it", "payment": response.NewPayment(record)})
+	default:
+		c.JSON(http.StatusCreated, gin.H{"message": "Payment recorded", "payment": response.NewPayment(record)})
+	}
+}
+
+// GetLeasePayments lists the payments of a lease, the latest first
+func (p *PaymentHandler) GetLeasePayments(c *gin.Context) {
+	lease, ok := p.leaseOf(c)
+	if !ok {
+		return
+	}
+	var payments []models.Payment
+	if err := p.DB.Where("lease_id = ?", lease.ID).Order("id DESC").Find(&payments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch payments"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"payments": response.NewPayments(payments)})
+}
+
+// RefundPayment pays back part of a payment, either from the deposit held or as a
+// reversal that the tenant owes again. Only the owner can refund.
+func (p *PaymentHandler) RefundPayment(c *gin.Context) {
+	var reqBody struct {
+		Amount      int64  `json:"amount" binding:"required,gt=0"`
+		FromDeposit bool   `json:"fromDeposit"`
+		Reason      string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	userID := c.GetUint("userId")
+	var record models.Payment
+	var failure string
+	err := p.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&record, c.Param("id")).Error; err != nil {
+			return err
+		}
+		// Refunds of a lease are made one at a time so the deposit can't be paid out twice
+		var lease models.Lease
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&lease, record.LeaseID).Error; err != nil {
+			return err
+		}
+		if !isLeaseParty(c, lease) {
+			return gorm.ErrRecordNotFound
+		}
+		if userID != lease.OwnerID && !utils.HasPermission(c, models.PermissionPropertyManageAny) {
+			return errRefundForbidden
+		}
+		if reqBody.Amount > record.Refundable() {
+			return errNotRefundable
+		}
+		debit := models.LedgerAccountTenantReceivable
+		if reqBody.FromDeposit {
+			balances, err := models.LedgerBalances(tx, lease.ID)
+			if err != nil {
+				return err
+			}
+			if reqBody.Amount > -balances[models.LedgerAccountDepositHeld] {
+				return errDepositExceeded
+			}
+			debit = models.LedgerAccountDepositHeld
+		}
+
+		// The provider is called while the lease is locked, the idempotency key makes a
+		// retry after a failed commit refund only once
+		if record.Method != models.PaymentMethodManual {
+			key := fmt.Sprintf("refund-%d-%d", record.ID, record.Refunded)
+			result, err := p.Payments.Refund(c.Request.Context(), record.Reference, reqBody.Amount, key)
+			if err != nil {
+				return err
+			}
+			if result.Status != payment.StatusSucceeded {
+				failure = result.FailureReason
+				return nil
+			}
+		}
+
+		description := "refund"
+		if reqBody.Reason != "" {
+			description += ": " + reqBody.Reason
+		}
+		journal := models.NewLedgerJournal(lease.ID, models.LedgerKindRefund, description, debit, models.LedgerAccountCash, reqBody.Amount)
+		journal.PaymentID = &record.ID
+		if err := models.PostLedgerJournal(tx, &journal); err != nil {
+			return err
+		}
+		record.Refunded += reqBody.Amount
+		return tx.Model(&record).Update("refunded", record.Refunded).Error
+	})
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+	case errors.Is(err, errRefundForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the owner can refund payments"})
+	case errors.Is(err, errNotRefundable):
+		c.JSON(http.StatusConflict, gin.H{"error": "Amount exceeds what can be refunded", "refundable": record.Refundable()})
+	case errors.Is(err, errDepositExceeded):
+		c.JSON(http.StatusConflict, gin.H{"error": "Amount exceeds the deposit held"})
+	case errors.Is(err, payment.ErrUnavailable):
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Payment provider is not available"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refund payment"})
+	case failure != "":
+		c.JSON(http.StatusConflict, gin.H{"error": "Refund failed: " + failure})
+	default:
+		c.JSON(http.StatusOK, gin.H{"message": "Payment refunded", "payment": response.NewPayment(record)})
+	}
+}
+
+// GetLedger lists the journals of the lease with their entries, in the order they were
+// booked
+func (p *PaymentHandler) GetLedger(c *gin.Context) {
+	lease, ok := p.leaseOf(c)
+	if !ok {
+		return
+	}
+	var journals []models.LedgerJournal
+	if err := p.DB.Preload("Entries").Where("lease_id = ?", lease.ID).Order("id").Find(&journals).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch ledger"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"currency": p.Currency, "journals": response.NewLedgerJournals(journals)})
+}
+
+// GetLeaseBalance returns what the tenant owes, the deposit held and the rent schedule
+// with what was paid of each installment
+func (p *PaymentHandler) GetLeaseBalance(c *gin.Context) {
+	lease, ok := p.leaseOf(c)
+	if !ok {
+		return
+	}
+	if err := p.DB.Order("number").Find(&lease.Installments, "lease_id = ?", lease.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch balance"})
+		return
+	}
+	balance, err := p.leaseBalance(lease, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch balance"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"balance": balance})
+}
+
+// GetOverdueInstallments lists the overdue rent of every lease the user is the tenant or
+// owner of
+func (p *PaymentHandler) GetOverdueInstallments(c *gin.Context) {
+	userID := c.GetUint("userId")
+	now := time.Now()
+	var leases []models.Lease
+	err := p.DB.Preload("Installments", func(db *gorm.DB) *gorm.DB { return db.Order("number") }).
+		Where("tenant_id = ? OR owner_id = ?", userID, userID).
+		Where("id IN (?)", p.DB.Model(&models.RentInstallment{}).Select("lease_id").
+			Where("status = ? AND due_date < ?", models.RentInstallmentStatusCharged, now)).
+		Find(&leases).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch overdue rent"})
+		return
+	}
+
+	overdue := []response.RentInstallment{}
+	for _, lease := range leases {
+		balance, err := p.leaseBalance(lease, now)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch overdue rent"})
+			return
+		}
+		for _, installment := range balance.Installments {
+			if installment.Overdue {
+				overdue = append(overdue, installment)
+			}
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"currency": p.Currency, "installments": overdue})
+}
+
+// leaseBalance sums up the ledger of the lease, its installments have to be loaded. What
+// the tenant paid covers the deposit first and then the oldest installments.
+func (p *PaymentHandler) leaseBalance(lease models.Lease, now time.Time) (response.LeaseBalance, error) {
+	balances, err := models.LedgerBalances(p.DB, lease.ID)
+	if err != nil {
+		return response.LeaseBalance{}, err
+	}
+	balance := response.LeaseBalance{
+		LeaseID:      lease.ID,
+		Currency:     p.Currency,
+		Owed:         balances[models.LedgerAccountTenantReceivable],
+		DepositHeld:  -balances[models.LedgerAccountDepositHeld],
+		RentCharged:  -balances[models.LedgerAccountRentIncome],
+		Collected:    balances[models.LedgerAccountCash],
+		Installments: make([]response.RentInstallment, 0, len(lease.Installments)),
+	}
+
+	deposit := models.Cents(lease.Deposit)
+	charged := deposit
+	for _, installment := range lease.Installments {
+		if installment.Status == models.RentInstallmentStatusCharged {
+			charged += installment.Amount
+		}
+	}
+	paid := models.AllocateRentPayments(deposit, lease.Installments, charged-balance.Owed)
+	for i, installment := range lease.Installments {
+		item := response.NewRentInstallment(installment, paid[i], now)
+		if item.Overdue {
+			balance.Overdue += item.Amount - item.Paid
+		}
+		balance.Installments = append(balance.Installments, item)
+	}
+	return balance, nil
+}
+
+// leaseOf loads the lease of the request and responds with 404 unless the user is a party
+// of it
+func (p *PaymentHandler) leaseOf(c *gin.Context) (models.Lease, bool) {
+	var lease models.Lease
+	if err := p.DB.First(&lease, c.Param("id")).Error; err != nil || !isLeaseParty(c, lease) {
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch lease"})
+			return lease, false
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "Lease not found"})
+		return lease, false
+	}
+	return lease, true
+}
+
+// scheduleSettlement enqueues the check whether a pending payment settled
+func (p *PaymentHandler) scheduleSettlement(c *gin.Context, record models.Payment) {
+	if p.Jobs == nil {
+		return
+	}
+	err := queue.Enqueue(c.Request.Context(), p.Jobs, jobs.TypeSettlePayment, jobs.SettlePayment{PaymentID: record.ID},
+		queue.RunAt(time.Now().Add(settleCheckDelay)), queue.MaxAttempts(settleCheckAttempts))
+	if err != nil {
+		log.Printf("Failed to schedule settlement of payment %d: %v", record.ID, err)
+	}
+}
@@ -5,44 +5,67 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"golang-test/api/response"
+	"golang-test/cache"
 	"golang-test/config"
+	"golang-test/jobs"
 	"golang-test/models"
+	"golang-test/queue"
+	"golang-test/storage"
 	"golang-test/utils"
 	"log"
 	"net/http"
 	"time"
 
+	"slices"
+	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/go-redis/redis/v8"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
 type PropertiesHandler struct {
 	DB *gorm.DB
-	Redis *redis.Client
+	Cache cache.Cache
+	Storage storage.Storage
+	Jobs queue.Queue
 }
 
 // Function to cache properties results
-func (p *PropertiesHandler) cacheProperties(key string, properties []models.Property) error {
+func (p *PropertiesHandler) cacheProperties(key string, page *response.PropertyPage) error {
+	if p.Cache == nil {
+		return nil
+	}
 	// Serialize properties to JSON
-	propertiesJSON, err := json.Marshal(properties)
+	propertiesJSON, err := json.Marshal(page)
 	if err != nil {
 		return err
 	}
 	
 	// Cache with expiration (e.g., 10 minutes)
-	return p.Redis.Set(context.Background(), key, propertiesJSON, 120*time.Minute).Err()
+	return p.Cache.Set(context.Background(), key, propertiesJSON, 120*time.Minute)
+}
+
+// propertyListingCacheKey adds the versions of the listing's tags to the key
+func (p *PropertiesHandler) propertyListingCacheKey(ctx context.Context, key string, tags []string) (string, error) {
+	if p.Cache == nil {
+		return "", nil
+	}
+	return cache.Tagged{Cache: p.Cache}.Key(ctx, key, tags...)
 }
 
 // Function to get properties from cache
-func (p *PropertiesHandler) getPropertiesFromCache(key string) ([]models.Property, error) {
+func (p *PropertiesHandler) getPropertiesFromCache(key string) (*response.PropertyPage, error) {
+	if p.Cache == nil {
+		return nil, nil
+	}
 	// Try to get data from cache
-	val, err := p.Redis.Get(context.Background(), key).Result()
+	val, err := p.Cache.Get(context.Background(), key)
 	if err != nil {
-		if err == redis.Nil {
+		if errors.Is(err, cache.ErrMiss) {
 			// Key does not exist
 			return nil, nil
 		}
@@ -50,65 +73,242 @@ func (p *PropertiesHandler) getPropertiesFromCache(key string) ([]models.Propert
 	}
 	
 	// Deserialize JSON to properties
-	var properties []models.Property
-	if err := json.Unmarshal([]byte(val), &properties); err != nil {
+	var page response.PropertyPage
+	if err := json.Unmarshal(val, &page); err != nil {
 		return nil, err
 	}
 	
-	return properties, nil
+	return &page, nil
 }
 
-// Function to invalidate property cache
-func (p *PropertiesHandler) invalidatePropertyCache() error {
-	// Use KEYS pattern to find all property-related cache keys
-	// Note: In production, consider using SCAN for large datasets
-	keys, err := p.Redis.Keys(context.Background(), "properties:*").Result()
-	if err != nil {
-		return err
-	}
-	
-	// If there are keys to delete
-	if len(keys) > 0 {
-		return p.Redis.Del(context.Background(), keys...).Err()
-	}
-	
-	return nil
+// invalidatePropertyCache drops the cached listings the properties can show up in
+func (p *PropertiesHandler) invalidatePropertyCache(properties ...models.Property) {
+	invalidateCache(p.Cache, propertyTags(properties...)...)
 }
 
 
+// propertySorts maps the accepted sort values to their column
+var propertySorts = map[string]string{
+	"price":      "price",
+	"-price":     "price",
+	"createdAt":  "created_at",
+	"-createdAt": "created_at",
+	"relevance":  propertyRankSQL,
+	"distance":   propertyDistanceSQL,
+}
+
+// Full-text search expressions over the search_vector column, the first two
+// placeholders of each are the text search configuration and the query
+const (
+	propertyRankSQL      = "ts_rank(search_vector, websearch_to_tsquery(?::regconfig, ?))"
+	propertyMatchSQL     = "search_vector @@ websearch_to_tsquery(?::regconfig, ?)"
+	propertyHighlightSQL = "ts_headline(?::regconfig, concat_ws(' ', name, description, location), websearch_to_tsquery(?::regconfig, ?), 'StartSel=<mark>, StopSel=</mark>, MaxFragments=2')"
+)
+
+// propertyDistanceSQL is the haversine distance in km from a point, its placeholders are
+// the point's latitude, latitude again and longitude. Rounding can take the square root
+// slightly above 1 for antipodal points, where asin is undefined, so it is capped.
+const propertyDistanceSQL = "(6371 * 2 * asin(least(1, sqrt(power(sin(radians(latitude - ?) / 2), 2) + cos(radians(?)) * cos(radians(latitude)) * power(sin(radians(longitude - ?) / 2), 2)))))"
+
+// likeEscaper escapes the LIKE wildcards and the escape character itself
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// propertyRangeFilters maps the attribute range query parameters to their condition
+var propertyRangeFilters = []struct {
+	Param     string
+	Condition string
+}{
+	{"minBedrooms", "bedrooms >= ?"},
+	{"maxBedrooms", "bedrooms <= ?"},
+	{"minBathrooms", "bathrooms >= ?"},
+	{"maxBathrooms", "bathrooms <= ?"},
+	{"minArea", "area >= ?"},
+	{"maxArea", "area <= ?"},
+	{"minFloor", "floor >= ?"},
+	{"maxFloor", "floor <= ?"},
+	{"minYearBuilt", "year_built >= ?"},
+	{"maxYearBuilt", "year_built <= ?"},
+}
+
+// propertyCursor is the keyset position encoded in nextCursor
+type propertyCursor struct {
+	Sort      string    `json:"s"`
+	Price     float64   `json:"p,omitempty"`
+	CreatedAt time.Time `json:"c,omitempty"`
+	Rank      float64   `json:"r,omitempty"`
+	Distance  float64   `json:"d,omitempty"`
+	ID        uint      `json:"i"`
+}
+
+// propertyMatch holds the values computed for a result row: its search ranking and
+// highlighted snippet, and its distance from the requested point
+type propertyMatch struct {
+	ID         uint
+	Rank       float64
+	Highlight  string
+	DistanceKm float64
+}
+
 func (p *PropertiesHandler) GetProperties(c *gin.Context) {
 	// Get filter parameters
 	categoryID := c.Query("categoryId")
 	propertyTypeID := c.Query("typeId")
+	ownerID := c.Query("ownerId")
+	listingMode := c.Query("listingMode")
 	description := c.Query("description")
 	minPrice := c.Query("minPrice")
 	maxPrice := c.Query("maxPrice")
+	searchQuery := strings.TrimSpace(c.Query("q"))
+	var err error
+	language := config.AppConfig.SearchLanguage
+	nearParam := c.Query("near")
+	radiusParam := c.Query("radiusKm")
+	bboxParam := c.Query("bbox")
+
+	// Parse the geospatial filters
+	var near *utils.LatLng
+	if nearParam != "" {
+		point, err := utils.ParseLatLng(nearParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid near: " + err.Error()})
+			return
+		}
+		near = &point
+	}
+	var radiusKm float64
+	if radiusParam != "" {
+		radiusKm, err = strconv.ParseFloat(radiusParam, 64)
+		if err != nil || radiusKm <= 0 || near == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "radiusKm must be a positive number and requires near"})
+			return
+		}
+	}
+	var bbox *utils.BoundingBox
+	if bboxParam != "" {
+		box, err := utils.ParseBoundingBox(bboxParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bbox: " + err.Error()})
+			return
+		}
+		bbox = &box
+	}
+
+	// Parse the attribute filters
+	rangeValues := map[string]float64{}
+	for _, filter := range propertyRangeFilters {
+		if value := c.Query(filter.Param); value != "" {
+			number, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + filter.Param})
+				return
+			}
+			rangeValues[filter.Param] = number
+		}
+	}
+	amenityNames := parseAmenityNames(c.Query("amenities"))
+	if listingMode != "" && !models.IsListingMode(listingMode) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "listingMode must be sale, rent or both"})
+		return
+	}
+	var statuses []string
+	if statusParam := c.Query("status"); statusParam != "" {
+		for _, status := range strings.Split(statusParam, ",") {
+			if !models.IsPropertyStatus(status) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status " + status})
+				return
+			}
+			statuses = append(statuses, status)
+		}
+		sort.Strings(statuses)
+		statuses = slices.Compact(statuses)
+	}
+	// Listings that aren't public are only shown to staff, and to owners listing their own
+	visibility := "public"
+	if p.canSeeAllListings(c) || (ownerID != "" && ownerID == strconv.FormatUint(uint64(c.GetUint("userId")), 10)) {
+		visibility = "all"
+	}
+
+	// Search results are ordered by relevance and nearby results by distance unless another sort is requested
+	defaultSort := "-createdAt"
+	if searchQuery != "" {
+		defaultSort = "relevance"
+	} else if near != nil {
+		defaultSort = "distance"
+	}
+	pagination, err := utils.ParsePagination(c, []string{"price", "-price", "createdAt", "-createdAt", "relevance", "distance"}, defaultSort)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if pagination.Sort == "relevance" && searchQuery == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort=relevance requires a search query"})
+		return
+	}
+	if pagination.Sort == "distance" && near == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort=distance requires near"})
+		return
+	}
+	var cursor propertyCursor
+	if pagination.Cursor != "" {
+		if err := utils.DecodeCursor(pagination.Cursor, &cursor); err != nil || cursor.Sort != pagination.Sort {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+	}
 	
 	// Create params map for cache key generation
 	params := map[string]string{
 		"categoryId": categoryID,
 		"typeId":     propertyTypeID,
+		"ownerId":    ownerID,
 		"description": description,
 		"minPrice":   minPrice,
 		"maxPrice":   maxPrice,
+		"q":          searchQuery,
+		"near":       nearParam,
+		"radiusKm":   radiusParam,
+		"bbox":       bboxParam,
+		"amenities":  strings.Join(amenityNames, ","),
+		"status":     strings.Join(statuses, ","),
+		"listingMode": listingMode,
+		"visibility": visibility,
+	}
+	for _, filter := range propertyRangeFilters {
+		params[filter.Param] = c.Query(filter.Param)
+	}
+	for key, value := range pagination.CacheParams() {
+		params[key] = value
 	}
 	
-	// Generate cache key
-	cacheKey := utils.GeneratePropertiesCacheKey(params)
+	// Generate cache key, it changes whenever a property the listing can contain is written
+	filterIDs := map[string]*uint{}
+	for param, value := range map[string]string{"categoryId": categoryID, "typeId": propertyTypeID, "ownerId": ownerID} {
+		if id, err := strconv.ParseUint(value, 10, 64); err == nil {
+			filterID := uint(id)
+			filterIDs[param] = &filterID
+		}
+	}
+	cacheKey, err := p.propertyListingCacheKey(c.Request.Context(), utils.GeneratePropertiesCacheKey(params),
+		listingTags(filterIDs["categoryId"], filterIDs["typeId"], filterIDs["ownerId"]))
 	
 	// Try to get from cache first
-	cachedProperties, err := p.getPropertiesFromCache(cacheKey)
+	var cachedPage *response.PropertyPage
+	if err == nil {
+		cachedPage, err = p.getPropertiesFromCache(cacheKey)
+	}
 	if err != nil {
 		// Log the cache error but continue with database query
 		log.Printf("Cache error: %v", err)
-	} else if cachedProperties != nil {
+	} else if cachedPage != nil {
 		// Return cached data if available
-		c.JSON(http.StatusOK, gin.H{"properties": cachedProperties, "source": "cache"})
+		cachedPage.Source = "cache"
+		p.signPropertyImageURLs(c.Request.Context(), cachedPage.Properties...)
+		c.JSON(http.StatusOK, cachedPage)
 		return
 	}
 	
 	// Build query
-	query := p.DB.Model(models.Property{}).Preload(clause.Associations)
+	query := p.DB.Model(models.Property{})
 	
 	// Apply filters if provided
 	if categoryID != "" {
@@ -123,9 +323,28 @@ func (p *PropertiesHandler) GetProperties(c *gin.Context) {
 		}
 	}
 	
-	// Apply description search if provided
+	if ownerID != "" {
+		if id, err := strconv.Atoi(ownerID); err == nil {
+			query = query.Where("owner_id = ?", id)
+		}
+	}
+	
+	if len(statuses) > 0 {
+		query = query.Where("status IN ?", statuses)
+	}
+	
+	// Properties offered both ways match either listing mode
+	if listingMode != "" {
+		query = query.Where("listing_mode IN ?", []string{listingMode, models.ListingModeBoth})
+	}
+	if visibility == "public" {
+		query = query.Where("status IN ?", models.PublicPropertyStatuses)
+	}
+	
+	// Apply description search if provided, case-insensitive and with wildcards in the
+	// input matched literally
 	if description != "" {
-		query = query.Where("description LIKE ?", "%"+description+"%")
+		query = query.Where("description ILIKE ?", "%"+likeEscaper.Replace(description)+"%")
 	}
 	
 	// Apply price range filters if provided
@@ -140,29 +359,201 @@ func (p *PropertiesHandler) GetProperties(c *gin.Context) {
 			query = query.Where("price <= ?", price)
 		}
 	}
+
+	// Apply attribute filters
+	for _, filter := range propertyRangeFilters {
+		if value, ok := rangeValues[filter.Param]; ok {
+			query = query.Where(filter.Condition, value)
+		}
+	}
+
+	// Only properties that have every requested amenity match
+	if len(amenityNames) > 0 {
+		query = query.Where(`id IN (SELECT property_amenities.property_id FROM property_amenities
+			JOIN amenities ON amenities.id = property_amenities.amenity_id
+			WHERE amenities.name IN ? AND amenities.deleted_at IS NULL
+			GROUP BY property_amenities.property_id
+			HAVING count(DISTINCT amenities.id) = ?)`, amenityNames, len(amenityNames))
+	}
+
+	// Apply full-text search over name, description and location
+	if searchQuery != "" {
+		query = query.Where(propertyMatchSQL, language, searchQuery)
+	}
+
+	// Apply geospatial filters, rows without coordinates can't match them
+	if near != nil {
+		query = query.Where("latitude IS NOT NULL AND longitude IS NOT NULL")
+	}
+	if radiusKm > 0 {
+		// The bounding box lets the coordinate index narrow the rows before distances are computed
+		if box, ok := near.RadiusBounds(radiusKm); ok {
+			query = query.Where("latitude BETWEEN ? AND ? AND longitude BETWEEN ? AND ?", box.MinLat, box.MaxLat, box.MinLng, box.MaxLng)
+		} else {
+			query = query.Where("latitude BETWEEN ? AND ?", box.MinLat, box.MaxLat)
+		}
+		query = query.Where(propertyDistanceSQL+" <= ?", near.Lat, near.Lat, near.Lng, radiusKm)
+	}
+	if bbox != nil {
+		query = query.Where("latitude BETWEEN ? AND ?", bbox.MinLat, bbox.MaxLat)
+		if bbox.MinLng <= bbox.MaxLng {
+			query = query.Where("longitude BETWEEN ? AND ?", bbox.MinLng, bbox.MaxLng)
+		} else {
+			query = query.Where("(longitude >= ? OR longitude <= ?)", bbox.MinLng, bbox.MaxLng)
+		}
+	}
+
+	// Count every match before paging is applied
+	query = query.Session(&gorm.Session{})
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch properties"})
+		return
+	}
+
+	// Sort by the requested column with the id as a tie breaker so keyset paging is stable
+	column := propertySorts[pagination.Sort]
+	var columnVars []interface{}
+	switch pagination.Sort {
+	case "relevance":
+		columnVars = []interface{}{language, searchQuery}
+	case "distance":
+		columnVars = []interface{}{near.Lat, near.Lat, near.Lng}
+	}
+	direction, comparison := "ASC", ">"
+	if pagination.Sort[0] == '-' || pagination.Sort == "relevance" {
+		direction, comparison = "DESC", "<"
+	}
+	listQuery := query.Preload(clause.Associations).
+		Order(clause.Expr{SQL: fmt.Sprintf("%s %s, id %s", column, direction, direction), Vars: columnVars}).
+		Limit(pagination.PageSize + 1)
+	if pagination.Cursor != "" {
+		var value interface{} = cursor.CreatedAt
+		switch pagination.Sort {
+		case "price", "-price":
+			value = cursor.Price
+		case "relevance":
+			value = cursor.Rank
+		case "distance":
+			value = cursor.Distance
+		}
+		listQuery = listQuery.Where(fmt.Sprintf("(%s, id) %s (?, ?)", column, comparison), append(columnVars, value, cursor.ID)...)
+	} else {
+		listQuery = listQuery.Offset(pagination.Offset())
+	}
 	
 	// Execute query
 	var properties []models.Property
-	if err := query.Find(&properties).Error; err != nil {
+	if err := listQuery.Find(&properties).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch properties"})
 		return
 	}
+
+	// The extra row only tells us whether there is a next page
+	hasMore := len(properties) > pagination.PageSize
+	if hasMore {
+		properties = properties[:pagination.PageSize]
+	}
+
+	serialized := response.NewProperties(properties)
+	matches := map[uint]propertyMatch{}
+	if (searchQuery != "" || near != nil) && len(properties) > 0 {
+		ids := make([]uint, 0, len(properties))
+		for _, property := range properties {
+			ids = append(ids, property.ID)
+		}
+		// Compute ranks, highlights and distances only for the rows on this page, ts_headline is expensive
+		selects := []string{"id"}
+		var vars []interface{}
+		if searchQuery != "" {
+			selects = append(selects, propertyRankSQL+" AS rank", propertyHighlightSQL+" AS highlight")
+			vars = append(vars, language, searchQuery, language, language, searchQuery)
+		}
+		if near != nil {
+			selects = append(selects, propertyDistanceSQL+" AS distance_km")
+			vars = append(vars, near.Lat, near.Lat, near.Lng)
+		}
+		var results []propertyMatch
+		if err := p.DB.Model(&models.Property{}).
+			Select(strings.Join(selects, ", "), vars...).
+			Where("id IN ?", ids).
+			Scan(&results).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch properties"})
+			return
+		}
+		for _, result := range results {
+			matches[result.ID] = result
+		}
+		for i := range serialized {
+			match := matches[serialized[i].ID]
+			serialized[i].Rank = match.Rank
+			serialized[i].Highlight = match.Highlight
+			if near != nil {
+				serialized[i].DistanceKm = &match.DistanceKm
+			}
+		}
+	}
+
+	page := &response.PropertyPage{
+		Properties: serialized,
+		Total:      total,
+		PageSize:   pagination.PageSize,
+		Links: map[string]string{
+			"self":  utils.PageLink(c, nil),
+			"first": utils.PageLink(c, map[string]string{"page": "", "cursor": ""}),
+		},
+	}
+	if hasMore {
+		last := properties[len(properties)-1]
+		nextCursor, err := utils.EncodeCursor(propertyCursor{
+			Sort:      pagination.Sort,
+			Price:     float64(last.Price),
+			CreatedAt: last.CreatedAt,
+			Rank:      matches[last.ID].Rank,
+			Distance:  matches[last.ID].DistanceKm,
+			ID:        last.ID,
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch properties"})
+			return
+		}
+		page.NextCursor = nextCursor
+	}
+	if pagination.Cursor == "" {
+		page.Page = pagination.Page
+		if hasMore {
+			page.Links["next"] = utils.PageLink(c, map[string]string{"page": strconv.Itoa(pagination.Page + 1)})
+		}
+		if pagination.Page > 1 {
+			page.Links["prev"] = utils.PageLink(c, map[string]string{"page": strconv.Itoa(pagination.Page - 1)})
+		}
+	} else if hasMore {
+		page.Links["next"] = utils.PageLink(c, map[string]string{"cursor": page.NextCursor})
+	}
 	
-	// Cache the results
-	if err := p.cacheProperties(cacheKey, properties); err != nil {
-		log.Printf("Failed to cache properties: %v", err)
+	// Cache the serialized results so cached entries never hold more than the response does
+	if cacheKey != "" {
+		if err := p.cacheProperties(cacheKey, page); err != nil {
+			log.Printf("Failed to cache properties: %v", err)
+		}
 	}
 	
-	c.JSON(http.StatusOK, gin.H{"properties": properties, "source": "database"})
+	page.Source = "database"
+	p.signPropertyImageURLs(c.Request.Context(), page.Properties...)
+	c.JSON(http.StatusOK, page)
 }
 
 
 // GetPropertyByID retrieves a single property by ID
 func (p *PropertiesHandler) GetPropertyByID(c *gin.Context) {
-	id := c.Param("id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Property not found"})
+		return
+	}
 	
-	var property models.Property
-	if err := p.DB.Preload(clause.Associations).First(&property, id).Error; err != nil {
+	property, err := p.findCachedProperty(c.Request.Context(), uint(id))
+	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Property not found"})
 			return
@@ -170,10 +561,80 @@ func (p *PropertiesHandler) GetPropertyByID(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch property"})
 		return
 	}
+	if !p.canViewProperty(c, property.Status, property.OwnerID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Property not found"})
+		return
+	}
 	
+	etag, lastModified := p.propertyValidators(property)
+	if utils.NotModified(c, etag, lastModified) {
+		return
+	}
+	p.signPropertyImageURLs(c.Request.Context(), property)
 	c.JSON(http.StatusOK, gin.H{"property": property})
 }
 
+// findCachedProperty loads the serialized property from the cache or from the database
+func (p *PropertiesHandler) findCachedProperty(ctx context.Context, id uint) (response.Property, error) {
+	// The key is versioned before loading, so a write that lands while the property is
+	// loaded leaves the entry unreachable instead of stale
+	var key string
+	if p.Cache != nil {
+		var err error
+		key, err = cache.Tagged{Cache: p.Cache}.Key(ctx, propertyTag(id), propertiesTag, propertyTag(id))
+		if err != nil {
+			log.Printf("Cache error: %v", err)
+		}
+	}
+	if key != "" {
+		if cached, err := p.Cache.Get(ctx, key); err == nil {
+			var property response.Property
+			if err := json.Unmarshal(cached, &property); err == nil {
+				return property, nil
+			}
+		} else if !errors.Is(err, cache.ErrMiss) {
+			log.Printf("Cache error: %v", err)
+		}
+	}
+	
+	var property models.Property
+	if err := p.DB.Preload(clause.Associations).First(&property, id).Error; err != nil {
+		return response.Property{}, err
+	}
+	serialized := response.NewProperty(property)
+	if key != "" {
+		if body, err := json.Marshal(serialized); err == nil {
+			if err := p.Cache.Set(ctx, key, body, 120*time.Minute); err != nil {
+				log.Printf("Failed to cache property: %v", err)
+			}
+		}
+	}
+	return serialized, nil
+}
+
+// propertyValidators derives the weak ETag and Last-Modified of a property from its
+// version and UpdatedAt, they are only used to revalidate with If-None-Match and
+// If-Modified-Since. When image URLs are presigned they change over time too, so the
+// validators also change with every signing window and a response revalidated with 304
+// never has expired image URLs.
+func (p *PropertiesHandler) propertyValidators(property response.Property) (string, time.Time) {
+	version := fmt.Sprintf("%d-%s", property.Version, strconv.FormatInt(property.UpdatedAt.UnixNano(), 36))
+	lastModified := property.UpdatedAt
+	if _, ok := storage.PresignerOf(p.Storage); ok && len(property.Images) > 0 {
+		window := time.Now().Truncate(imageURLWindow)
+		version += "-" + strconv.FormatInt(window.Unix(), 36)
+		if window.After(lastModified) {
+			lastModified = window
+		}
+	}
+	return fmt.Sprintf(`W/"%d-%s"`, property.ID, version), lastModified
+}
+
+// propertyVersionETag is the strong ETag of a version of the property, for If-Match
+func propertyVersionETag(id, version uint) string {
+	return fmt.Sprintf(`"%d-%d"`, id, version)
+}
+
 
 // CreateProperty creates a new property
 func (p *PropertiesHandler) CreateProperty(c *gin.Context) {
@@ -190,13 +651,20 @@ func (p *PropertiesHandler) CreateProperty(c *gin.Context) {
 	priceStr := c.PostForm("price")
 	propertyTypeIDStr := c.PostForm("propertyTypeId")
 	propertyCategoryIDStr := c.PostForm("propertyCategoryId")
+	latitudeStr := c.PostForm("latitude")
+	longitudeStr := c.PostForm("longitude")
+	listingMode := c.PostForm("listingMode")
 	
 	// Validate all required fields
 	if name == "" || description == "" || location == "" || priceStr == "" || 
-	   propertyTypeIDStr == "" || propertyCategoryIDStr == "" {
+	   propertyTypeIDStr == "" || propertyCategoryIDStr == "" || listingMode == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "All fields are required"})
 		return
 	}
+	if !models.IsListingMode(listingMode) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "listingMode must be sale, rent or both"})
+		return
+	}
 	
 	// Parse numeric values
 	price, err := strconv.ParseFloat(priceStr, 32)
@@ -216,6 +684,32 @@ func (p *PropertiesHandler) CreateProperty(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid property category ID"})
 		return
 	}
+	// Coordinates are optional but must be given together
+	var latitude, longitude *float64
+	if latitudeStr != "" || longitudeStr != "" {
+		lat, latErr := strconv.ParseFloat(latitudeStr, 64)
+		lng, lngErr := strconv.ParseFloat(longitudeStr, 64)
+		if latErr != nil || lngErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "latitude and longitude must both be valid numbers"})
+			return
+		}
+		if err := (utils.LatLng{Lat: lat, Lng: lng}).Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		latitude, longitude = &lat, &lng
+	}
+	// Parse the optional attributes and amenities
+	var attributes models.Property
+	if err := parsePropertyAttributes(c, &attributes); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	amenities, err := p.findAmenities(parseAmenityNames(c.PostForm("amenities")))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	// Validate that propertyTypeID exists in the database
 	var propertyType models.PropertyType
 	if err := p.DB.First(&propertyType, propertyTypeID).Error; err != nil {
@@ -240,16 +734,32 @@ func (p *PropertiesHandler) CreateProperty(c *gin.Context) {
 	property := models.Property{
 		Name:               name,
 		Description:        description,
-		Status:             "available", // Set default status as available
+		Status:             models.PropertyStatusDraft, // Listed once published and approved
+		ListingMode:        listingMode,
 		Price:              float32(price),
 		Location:           location,
+		Latitude:           latitude,
+		Longitude:          longitude,
+		Bedrooms:           attributes.Bedrooms,
+		Bathrooms:          attributes.Bathrooms,
+		Area:               attributes.Area,
+		Floor:              attributes.Floor,
+		YearBuilt:          attributes.YearBuilt,
+		Amenities:          amenities,
 		OwnerID:            userId.(uint),
 		PropertyTypeID:     uint(propertyTypeID),
 		PropertyCategoryID: uint(propertyCategoryID),
 	}
 	
 	// Create property record first to get the ID
-	if err := p.DB.Create(&property).Error; err != nil {
+	err = p.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&property).Error; err != nil {
+			return err
+		}
+		owner := property.OwnerID
+		return models.RecordPropertyStatus(tx, property.ID, "", property.Status, models.PropertyActorOwner, &owner, "")
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create property"})
 		return
 	}
@@ -269,32 +779,32 @@ func (p *PropertiesHandler) CreateProperty(c *gin.Context) {
 	imagePrefix := fmt.Sprintf("property/property_%d", property.ID)
 	property.ImagePrefix = imagePrefix
 	
-	cfg := config.AppConfig
-	bucketName := cfg.S3Bucket
-	
 	// Upload files using a worker pool
-	success := utils.UploadFilesWithWorkerPool(c, bucketName, imagePrefix, files)
+	uploaded, success := utils.UploadFilesWithWorkerPool(c, p.Storage, imagePrefix, files)
 	if !success {
 		// If file uploads failed, clean up the property
 		p.DB.Delete(&property)
 		return
 	}
 	
-	// Update the property with the image prefix
+	// Record the uploaded images, the first one is the cover
+	property.Images = newPropertyImages(property.ID, uploaded, 0)
+	property.Images[0].IsCover = true
+	
+	// Update the property with the image prefix and images
 	if err := p.DB.Save(&property).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update property with image information"})
 		// Clean up all uploaded files
-		cleanupS3Files(bucketName, imagePrefix, len(files))
+		p.cleanupFiles(uploadedKeys(uploaded))
 		return
 	}
 	
 	// Invalidate property cache after successful creation
-	if err := p.invalidatePropertyCache(); err != nil {
-		// Log the error but don't fail the request
-		log.Printf("Failed to invalidate property cache: %v", err)
-	}
+	p.invalidatePropertyCache(property)
 	
-	c.JSON(http.StatusCreated, gin.H{"message": "Property created successfully", "data": property})
+	serialized := response.NewProperty(property)
+	p.signPropertyImageURLs(c.Request.Context(), serialized)
+	c.JSON(http.StatusCreated, gin.H{"message": "Property created successfully", "data": serialized})
 }
 
 
@@ -319,25 +829,143 @@ func (p *PropertiesHandler) UpdateProperty(c *gin.Context) {
 		return
 	}
 	
-	// Bind new data
-	var updatedProperty models.Property
-	if err := c.BindJSON(&updatedProperty); err != nil {
+	// Bind new data, amenities are given by name
+	var reqBody struct {
+		models.Property
+		Amenities *[]string `json:"amenities"`
+	}
+	if err := c.BindJSON(&reqBody); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
 		return
 	}
+	updatedProperty := reqBody.Property
+	if err := validatePropertyAttributes(&updatedProperty); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var amenities []models.Amenity
+	if reqBody.Amenities != nil {
+		var err error
+		if amenities, err = p.findAmenities(parseAmenityNames(strings.Join(*reqBody.Amenities, ","))); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	
+	// The client has to say which version it edited so concurrent edits aren't lost
+	versions, ok := propertyPrecondition(c, property.ID, updatedProperty.Version)
+	if !ok {
+		c.JSON(http.StatusPreconditionRequired, gin.H{"error": "If-Match header or version is required"})
+		return
+	}
 	
 	// Preserve data that shouldn't be changed
 	updatedProperty.ID = property.ID
+	updatedProperty.Version = 0 // Incremented when saving
 	updatedProperty.OwnerID = property.OwnerID
-	updatedProperty.Status = property.Status // Status should be changed via transactions, not direct updates
+	updatedProperty.Status = property.Status // Status is changed through the status endpoints and transactions
+	updatedProperty.Amenities = nil
+	updatedProperty.ImagePrefix = property.ImagePrefix // Images are managed through the images endpoints
+	updatedProperty.Images = nil
+	if err := p.validatePropertyReferences(updatedProperty); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	
-	// Update the property
-	if err := p.DB.Model(&property).Updates(updatedProperty).Error; err != nil {
+	p.saveProperty(c, property, updatedProperty, updatedProperty, versions, amenities)
+}
+
+// saveProperty applies values to the property if its version is still one of versions,
+// replaces the amenities unless they are nil and responds with the stored property.
+// updated is the property after the change, its listings are invalidated as well.
+func (p *PropertiesHandler) saveProperty(c *gin.Context, property, updated models.Property, values interface{}, versions []uint, amenities []models.Amenity) {
+	// The version check is part of the update so a concurrent edit between reading and
+	// writing is caught as well
+	err := p.DB.Transaction(func(tx *gorm.DB) error {
+		query := tx.Model(&property).Omit(clause.Associations)
+		if versions != nil {
+			query = query.Where("version IN ?", versions)
+		}
+		result := query.Updates(values)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errPropertyVersionConflict
+		}
+		if err := tx.Model(&property).UpdateColumn("version", gorm.Expr("version + 1")).Error; err != nil {
+			return err
+		}
+		if amenities != nil {
+			return tx.Model(&property).Association("Amenities").Replace(amenities)
+		}
+		return nil
+	})
+	if errors.Is(err, errPropertyVersionConflict) {
+		p.respondWithCurrentProperty(c, http.StatusPreconditionFailed, property.ID, gin.H{"error": "Property was changed since it was read"})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update property"})
 		return
 	}
+	// The category or type may have changed, so listings of the old ones are stale too
+	p.invalidatePropertyCache(property, updated)
 	
-	c.JSON(http.StatusOK, gin.H{"message": "Property updated successfully", "data": property})
+	p.respondWithCurrentProperty(c, http.StatusOK, property.ID, gin.H{"message": "Property updated successfully"})
+}
+
+var errPropertyVersionConflict = errors.New("property version conflict")
+
+// propertyPrecondition returns the versions the client's edit may be applied to, taken
+// from the ETags in If-Match or else from the version in the body. If-Match uses the strong
+// comparison, so only the strong "<id>-<version>" ETag of write responses matches and
+// the weak ETag of GET responses never does. Image changes and new image URLs don't
+// change it, so they don't conflict with edits.
+// nil means any version ("If-Match: *"), ok is false when the client gave neither.
+func propertyPrecondition(c *gin.Context, id uint, bodyVersion uint) ([]uint, bool) {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		if bodyVersion == 0 {
+			return nil, false
+		}
+		return []uint{bodyVersion}, true
+	}
+	versions := []uint{}
+	for _, etag := range strings.Split(header, ",") {
+		etag = strings.TrimSpace(etag)
+		if etag == "*" {
+			return nil, true
+		}
+		if !strings.HasPrefix(etag, `"`) || !strings.HasSuffix(etag, `"`) {
+			continue
+		}
+		parts := strings.Split(strings.Trim(etag, `"`), "-")
+		if len(parts) != 2 || parts[0] != strconv.FormatUint(uint64(id), 10) {
+			continue
+		}
+		if version, err := strconv.ParseUint(parts[1], 10, 64); err == nil {
+			versions = append(versions, uint(version))
+		}
+	}
+	return versions, true
+}
+
+// respondWithCurrentProperty responds with the property as it is stored now and the strong
+// ETag the next edit can send in If-Match
+func (p *PropertiesHandler) respondWithCurrentProperty(c *gin.Context, status int, id uint, body gin.H) {
+	var property models.Property
+	if err := p.DB.Preload(clause.Associations).First(&property, id).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch property"})
+		return
+	}
+	serialized := response.NewProperty(property)
+	_, lastModified := p.propertyValidators(serialized)
+	c.Header("ETag", propertyVersionETag(serialized.ID, serialized.Version))
+	c.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	p.signPropertyImageURLs(c.Request.Context(), serialized)
+	body["data"] = serialized
+	c.JSON(status, body)
 }
 
 // DeleteProperty deletes a property
@@ -369,15 +997,135 @@ func (p *PropertiesHandler) DeleteProperty(c *gin.Context) {
 		return
 	}
 	
-	// Delete the property
-	if err := p.DB.Delete(&property).Error; err != nil {
+	// Delete the property and its images
+	var images []models.PropertyImage
+	err := p.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("property_id = ?", property.ID).Find(&images).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("property_id = ?", property.ID).Delete(&models.PropertyImage{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&property).Error
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete property"})
 		return
 	}
+	p.invalidatePropertyCache(property)
+	
+	// Remove the image files once the records are gone
+	var keys []string
+	for _, image := range images {
+		keys = append(keys, image.StorageKeys()...)
+	}
+	p.cleanupFiles(keys)
 	
 	c.JSON(http.StatusOK, gin.H{"message": "Property deleted successfully"})
 }
 
+// parseAmenityNames splits a comma separated list of amenity names, dropping duplicates
+func parseAmenityNames(value string) []string {
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return slices.Compact(names)
+}
+
+// findAmenities loads the named amenities and fails if any of them doesn't exist
+func (p *PropertiesHandler) findAmenities(names []string) ([]models.Amenity, error) {
+	amenities := []models.Amenity{}
+	if len(names) == 0 {
+		return amenities, nil
+	}
+	if err := p.DB.Where("name IN ?", names).Find(&amenities).Error; err != nil {
+		return nil, err
+	}
+	if len(amenities) != len(names) {
+		return nil, fmt.Errorf("unknown amenity")
+	}
+	return amenities, nil
+}
+
+// parsePropertyAttributes reads the optional attribute fields of a property form
+func parsePropertyAttributes(c *gin.Context, property *models.Property) error {
+	ints := []struct {
+		field string
+		dest  **int
+	}{
+		{"bedrooms", &property.Bedrooms},
+		{"bathrooms", &property.Bathrooms},
+		{"floor", &property.Floor},
+		{"yearBuilt", &property.YearBuilt},
+	}
+	for _, attribute := range ints {
+		if value := c.PostForm(attribute.field); value != "" {
+			number, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("Invalid %s", attribute.field)
+			}
+			*attribute.dest = &number
+		}
+	}
+	if value := c.PostForm("area"); value != "" {
+		area, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("Invalid area")
+		}
+		property.Area = &area
+	}
+	return validatePropertyAttributes(property)
+}
+
+// validatePropertyAttributes checks the ranges of the attributes that are set
+func validatePropertyAttributes(property *models.Property) error {
+	if property.ListingMode != "" && !models.IsListingMode(property.ListingMode) {
+		return fmt.Errorf("listingMode must be sale, rent or both")
+	}
+	if property.Bedrooms != nil && *property.Bedrooms < 0 {
+		return fmt.Errorf("bedrooms must not be negative")
+	}
+	if property.Bathrooms != nil && *property.Bathrooms < 0 {
+		return fmt.Errorf("bathrooms must not be negative")
+	}
+	if property.Area != nil && *property.Area <= 0 {
+		return fmt.Errorf("area must be positive")
+	}
+	if property.YearBuilt != nil && (*property.YearBuilt < 1000 || *property.YearBuilt > time.Now().Year()+10) {
+		return fmt.Errorf("yearBuilt is out of range")
+	}
+	if property.Latitude != nil || property.Longitude != nil {
+		if property.Latitude == nil || property.Longitude == nil {
+			return fmt.Errorf("latitude and longitude must be given together")
+		}
+		if err := (utils.LatLng{Lat: *property.Latitude, Lng: *property.Longitude}).Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validatePropertyReferences checks that the property type and category exist, unset ids
+// are left alone
+func (p *PropertiesHandler) validatePropertyReferences(property models.Property) error {
+	if property.PropertyTypeID != 0 {
+		if err := p.DB.First(&models.PropertyType{}, property.PropertyTypeID).Error; err != nil {
+			return fmt.Errorf("Property type does not exist")
+		}
+	}
+	if property.PropertyCategoryID != 0 {
+		if err := p.DB.First(&models.PropertyCategory{}, property.PropertyCategoryID).Error; err != nil {
+			return fmt.Errorf("Property category does not exist")
+		}
+	}
+	return nil
+}
+
 // Helper to check if user is authorized to modify a property
 func (p *PropertiesHandler) canModifyProperty(c *gin.Context, ownerID uint) bool {
 	// Get user ID from context (set by auth middleware)
@@ -391,25 +1139,47 @@ func (p *PropertiesHandler) canModifyProperty(c *gin.Context, ownerID uint) bool
 		return true
 	}
 	
-	// Check if user is admin
-	roleID, exists := c.Get("roleId")
-	if !exists {
-		return false
+	// Otherwise the role must be allowed to manage any listing
+	return utils.HasPermission(c, models.PermissionPropertyManageAny)
+}
+
+// newPropertyImages builds the image records for uploaded files, starting at the given position
+func newPropertyImages(propertyID uint, uploaded []utils.FileUploadResult, position int) []models.PropertyImage {
+	images := make([]models.PropertyImage, 0, len(uploaded))
+	for i, result := range uploaded {
+		images = append(images, models.PropertyImage{
+			PropertyID:  propertyID,
+			Key:         result.FileKey,
+			ContentType: result.ContentType,
+			Size:        result.Size,
+			Width:       result.Width,
+			Height:      result.Height,
+			Position:    position + i,
+			Renditions:  result.Renditions,
+		})
 	}
-	
-	// Get the role name
-	var role models.Role
-	if err := p.DB.First(&role, roleID).Error; err != nil {
-		return false
+	return images
+}
+
+func uploadedKeys(uploaded []utils.FileUploadResult) []string {
+	var keys []string
+	for _, result := range uploaded {
+		keys = append(keys, result.Keys()...)
 	}
-	
-	// Return true if admin
-	return role.Name == "admin"
+	return keys
 }
 
-func cleanupS3Files(bucketName, imagePrefix string, count int) {
-	for i := 0; i < count; i++ {
-		fileKey := fmt.Sprintf("%s/image_%d", imagePrefix, i)
-		utils.DeleteFileFromS3(bucketName, fileKey)
+// cleanupFiles removes the files in the background, or right away if the job can't be queued
+func (p *PropertiesHandler) cleanupFiles(keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	if p.Jobs != nil {
+		err := queue.Enqueue(context.Background(), p.Jobs, jobs.TypeDeleteFiles, jobs.DeleteFiles{Keys: keys})
+		if err == nil {
+			return
+		}
+		log.Printf("Failed to queue file cleanup: %v", err)
 	}
+	utils.CleanupFiles(p.Storage, keys)
 }
@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPropertyPrecondition(t *testing.T) {
+	tests := []struct {
+		ifMatch     string
+		bodyVersion uint
+		want        []uint
+		ok          bool
+	}{
+		{"", 0, nil, false},
+		{"", 3, []uint{3}, true},
+		{`"7-3"`, 0, []uint{3}, true},
+		{`"7-3", "7-4"`, 9, []uint{3, 4}, true},
+		{"*", 0, nil, true},
+		// Weak ETags never match under the strong comparison If-Match uses
+		{`W/"7-3"`, 0, []uint{}, true},
+		{`W/"7-3-abc-def"`, 3, []uint{}, true},
+		{`"8-3"`, 0, []uint{}, true},
+		{`"7-3-abc"`, 0, []uint{}, true},
+		{`7-3`, 0, []uint{}, true},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPut, "/properties/7", nil)
+		if tt.ifMatch != "" {
+			c.Request.Header.Set("If-Match", tt.ifMatch)
+		}
+		got, ok := propertyPrecondition(c, 7, tt.bodyVersion)
+		if ok != tt.ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("If-Match %s: got %v, %v, want %v, %v", tt.ifMatch, got, ok, tt.want, tt.ok)
+		}
+	}
+	if etag := propertyVersionETag(7, 3); etag != `"7-3"` {
+		t.Fatalf("got %s", etag)
+	}
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"golang-test/cache"
+	"golang-test/models"
+	"log"
+)
+
+// Cached listings carry tags so writes only invalidate the listings they can show up in
+const (
+	// propertiesTag is on every listing, invalidating it drops all of them
+	propertiesTag = "properties"
+	// propertyListTag is on listings that aren't narrowed to a category, type or owner
+	propertyListTag = "properties:list"
+)
+
+func propertyTag(id uint) string         { return fmt.Sprintf("property:%d", id) }
+func propertyCategoryTag(id uint) string { return fmt.Sprintf("properties:category:%d", id) }
+func propertyTypeTag(id uint) string     { return fmt.Sprintf("properties:type:%d", id) }
+func propertyOwnerTag(id uint) string    { return fmt.Sprintf("properties:owner:%d", id) }
+
+// listingTags are the tags of a listing with the given filters, nil means not filtered
+func listingTags(categoryID, typeID, ownerID *uint) []string {
+	tags := []string{propertiesTag}
+	if categoryID != nil {
+		tags = append(tags, propertyCategoryTag(*categoryID))
+	}
+	if typeID != nil {
+		tags = append(tags, propertyTypeTag(*typeID))
+	}
+	if ownerID != nil {
+		tags = append(tags, propertyOwnerTag(*ownerID))
+	}
+	if len(tags) == 1 {
+		tags = append(tags, propertyListTag)
+	}
+	return tags
+}
+
+// propertyTags are the tags of the properties' own entries and of every listing they can
+// show up in. For an update both the old and the new version should be passed.
+func propertyTags(properties ...models.Property) []string {
+	tags := []string{propertyListTag}
+	for _, property := range properties {
+		tags = append(tags,
+			propertyTag(property.ID),
+			propertyCategoryTag(property.PropertyCategoryID),
+			propertyTypeTag(property.PropertyTypeID),
+			propertyOwnerTag(property.OwnerID),
+		)
+	}
+	return uniqueStrings(tags)
+}
+
+// invalidateCache invalidates the tags. Failures are only logged, the write has
+// already happened and the entries expire on their own.
+func invalidateCache(c cache.Cache, tags ...string) {
+	if c == nil {
+		return
+	}
+	if err := (cache.Tagged{Cache: c}).Invalidate(context.Background(), tags...); err != nil {
+		log.Printf("Failed to invalidate cache tags %v: %v", tags, err)
+	}
+}
+
+// PropertyCacheInvalidator returns a function that drops the cached entries of properties
+// changed outside of a request, e.g. by background jobs
+func PropertyCacheInvalidator(c cache.Cache) func(properties ...models.Property) {
+	return func(properties ...models.Property) {
+		invalidateCache(c, propertyTags(properties...)...)
+	}
+}
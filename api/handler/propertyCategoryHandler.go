@@ -1,7 +1,9 @@
 package handler
 
 import (
+	"golang-test/api/response"
 	"golang-test/models"
+	"golang-test/utils"
 	"net/http"
 	"strings"
 
@@ -35,5 +37,5 @@ func (p *PropertyCategoryHandler) CreateCategory(c *gin.Context) {
 func (p *PropertyCategoryHandler) GetCategories(c *gin.Context) {
 	var categories []models.PropertyCategory
 	p.DB.Model(models.PropertyCategory{}).Find(&categories)
-	c.JSON(http.StatusOK, gin.H{"categories": categories})
+	utils.JSONWithETag(c, gin.H{"categories": response.NewPropertyCategories(categories)})
 }
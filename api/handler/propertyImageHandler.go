@@ -0,0 +1,275 @@
+package handler
+
+import (
+	"errors"
+	"golang-test/api/response"
+	"golang-test/models"
+	"golang-test/utils"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxPropertyImages is the number of images a single property can have
+const maxPropertyImages = 30
+
+// GetPropertyImages lists the images of a property in display order
+func (p *PropertiesHandler) GetPropertyImages(c *gin.Context) {
+	var property models.Property
+	if err := p.DB.First(&property, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Property not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch property"})
+		return
+	}
+	if !p.canViewProperty(c, property.Status, property.OwnerID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Property not found"})
+		return
+	}
+
+	images, err := p.propertyImages(p.DB, property.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch images"})
+		return
+	}
+	serialized := response.NewPropertyImages(images)
+	p.signImageURLs(c.Request.Context(), serialized)
+	c.JSON(http.StatusOK, gin.H{"images": serialized})
+}
+
+// AddPropertyImages uploads more images and appends them after the existing ones
+func (p *PropertiesHandler) AddPropertyImages(c *gin.Context) {
+	property, ok := p.findModifiableProperty(c)
+	if !ok {
+		return
+	}
+
+	if err := c.Request.ParseMultipartForm(10 << 20); err != nil { // 10 MB max memory
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to parse multipart form"})
+		return
+	}
+	form, _ := c.MultipartForm()
+	files := form.File["images"]
+	if len(files) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one image is required"})
+		return
+	}
+
+	existing, err := p.propertyImages(p.DB, property.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch images"})
+		return
+	}
+	if len(existing)+len(files) > maxPropertyImages {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A property can have at most 30 images"})
+		return
+	}
+
+	uploaded, success := utils.UploadFilesWithWorkerPool(c, p.Storage, property.ImagePrefix, files)
+	if !success {
+		return
+	}
+
+	images := newPropertyImages(property.ID, uploaded, len(existing))
+	if len(existing) == 0 {
+		images[0].IsCover = true
+	}
+	if err := p.DB.Create(&images).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save images"})
+		p.cleanupFiles(uploadedKeys(uploaded))
+		return
+	}
+
+	p.respondWithImages(c, http.StatusCreated, property, "Images added successfully")
+}
+
+// DeletePropertyImage removes an image from the property and from storage
+func (p *PropertiesHandler) DeletePropertyImage(c *gin.Context) {
+	property, ok := p.findModifiableProperty(c)
+	if !ok {
+		return
+	}
+
+	var image models.PropertyImage
+	if err := p.DB.Where("property_id = ?", property.ID).First(&image, c.Param("imageId")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch image"})
+		return
+	}
+
+	var count int64
+	p.DB.Model(&models.PropertyImage{}).Where("property_id = ?", property.ID).Count(&count)
+	if count <= 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A property must keep at least one image"})
+		return
+	}
+
+	err := p.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Delete(&image).Error; err != nil {
+			return err
+		}
+		return p.reindexPropertyImages(tx, property.ID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete image"})
+		return
+	}
+	p.cleanupFiles(image.StorageKeys())
+
+	p.respondWithImages(c, http.StatusOK, property, "Image deleted successfully")
+}
+
+// ReorderPropertyImages sets the display order, the body must list every image id once
+func (p *PropertiesHandler) ReorderPropertyImages(c *gin.Context) {
+	property, ok := p.findModifiableProperty(c)
+	if !ok {
+		return
+	}
+
+	var reqBody struct {
+		ImageIDs []uint `json:"imageIds" binding:"required"`
+	}
+	if err := c.BindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	err := p.DB.Transaction(func(tx *gorm.DB) error {
+		images, err := p.propertyImages(tx, property.ID)
+		if err != nil {
+			return err
+		}
+		positions := make(map[uint]int, len(reqBody.ImageIDs))
+		for i, id := range reqBody.ImageIDs {
+			positions[id] = i
+		}
+		if len(positions) != len(reqBody.ImageIDs) || len(positions) != len(images) {
+			return errInvalidImageOrder
+		}
+		for _, image := range images {
+			position, ok := positions[image.ID]
+			if !ok {
+				return errInvalidImageOrder
+			}
+			if err := tx.Model(&image).Update("position", position).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errInvalidImageOrder) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "imageIds must list every image of the property exactly once"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reorder images"})
+		return
+	}
+
+	p.respondWithImages(c, http.StatusOK, property, "Images reordered successfully")
+}
+
+// SetPropertyCoverImage makes the image the cover of its property
+func (p *PropertiesHandler) SetPropertyCoverImage(c *gin.Context) {
+	property, ok := p.findModifiableProperty(c)
+	if !ok {
+		return
+	}
+
+	var image models.PropertyImage
+	if err := p.DB.Where("property_id = ?", property.ID).First(&image, c.Param("imageId")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch image"})
+		return
+	}
+
+	err := p.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.PropertyImage{}).Where("property_id = ? AND id <> ?", property.ID, image.ID).Update("is_cover", false).Error; err != nil {
+			return err
+		}
+		return tx.Model(&image).Update("is_cover", true).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set cover image"})
+		return
+	}
+
+	p.respondWithImages(c, http.StatusOK, property, "Cover image updated successfully")
+}
+
+var errInvalidImageOrder = errors.New("invalid image order")
+
+// findModifiableProperty loads the property from the id path parameter and checks that
+// the user may modify it, writing the error response if not
+func (p *PropertiesHandler) findModifiableProperty(c *gin.Context) (models.Property, bool) {
+	var property models.Property
+	if err := p.DB.First(&property, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Property not found"})
+			return property, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch property"})
+		return property, false
+	}
+	if !p.canModifyProperty(c, property.OwnerID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to update this property"})
+		return property, false
+	}
+	return property, true
+}
+
+func (p *PropertiesHandler) propertyImages(db *gorm.DB, propertyID uint) ([]models.PropertyImage, error) {
+	var images []models.PropertyImage
+	err := db.Where("property_id = ?", propertyID).Order("position, id").Find(&images).Error
+	return images, err
+}
+
+// reindexPropertyImages closes gaps in the positions and makes sure there is a cover
+func (p *PropertiesHandler) reindexPropertyImages(tx *gorm.DB, propertyID uint) error {
+	images, err := p.propertyImages(tx, propertyID)
+	if err != nil {
+		return err
+	}
+	hasCover := false
+	for i, image := range images {
+		hasCover = hasCover || image.IsCover
+		if image.Position != i {
+			if err := tx.Model(&image).Update("position", i).Error; err != nil {
+				return err
+			}
+		}
+	}
+	if !hasCover && len(images) > 0 {
+		return tx.Model(&images[0]).Update("is_cover", true).Error
+	}
+	return nil
+}
+
+func (p *PropertiesHandler) respondWithImages(c *gin.Context, status int, property models.Property, message string) {
+	// Images are part of the property, touching it changes its ETag
+	if err := p.DB.Model(&property).UpdateColumn("updated_at", time.Now()).Error; err != nil {
+		log.Printf("Failed to touch property %d: %v", property.ID, err)
+	}
+	// Listings embed the images, so cached pages are stale now
+	p.invalidatePropertyCache(property)
+
+	images, err := p.propertyImages(p.DB, property.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch images"})
+		return
+	}
+	serialized := response.NewPropertyImages(images)
+	p.signImageURLs(c.Request.Context(), serialized)
+	c.JSON(status, gin.H{"message": message, "images": serialized})
+}
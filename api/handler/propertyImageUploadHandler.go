@@ -0,0 +1,196 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"golang-test/api/response"
+	"golang-test/models"
+	"golang-test/storage"
+	"golang-test/utils"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// acceptedImageTypes are the content types clients can upload, the content itself is
+// checked when the upload is confirmed
+var acceptedImageTypes = map[string]bool{"image/jpeg": true, "image/png": true, "image/webp": true}
+
+const (
+	// imageUploadURLExpiry is how long a presigned upload URL can be used
+	imageUploadURLExpiry = 15 * time.Minute
+	// imageDownloadURLExpiry is how long the image URLs in responses stay valid
+	imageDownloadURLExpiry = 15 * time.Minute
+	// imageURLWindow is how long a client may keep using a response's image URLs after
+	// revalidating it, it has to stay well below imageDownloadURLExpiry
+	imageURLWindow = 5 * time.Minute
+)
+
+type imageUpload struct {
+	Key       string              `json:"key"`
+	URL       string              `json:"url"`
+	Method    string              `json:"method"`
+	Headers   map[string][]string `json:"headers"`
+	ExpiresAt time.Time           `json:"expiresAt"`
+}
+
+// RequestPropertyImageUploads hands out presigned URLs the client uploads the images to
+// directly, the images are added to the property once the uploads are confirmed
+func (p *PropertiesHandler) RequestPropertyImageUploads(c *gin.Context) {
+	presigner, ok := storage.PresignerOf(p.Storage)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Direct uploads are not supported by the storage driver"})
+		return
+	}
+
+	property, ok := p.findModifiableProperty(c)
+	if !ok {
+		return
+	}
+
+	var reqBody struct {
+		Images []struct {
+			Filename    string `json:"filename" binding:"required"`
+			ContentType string `json:"contentType" binding:"required"`
+			Size        int64  `json:"size" binding:"required"`
+		} `json:"images" binding:"required,min=1,dive"`
+	}
+	if err := c.BindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	for _, image := range reqBody.Images {
+		if !acceptedImageTypes[image.ContentType] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s must be a JPEG, PNG or WebP image", image.Filename)})
+			return
+		}
+		if image.Size <= 0 || image.Size > utils.MaxImageSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s must be at most 20 MB", image.Filename)})
+			return
+		}
+	}
+
+	var count int64
+	if err := p.DB.Model(&models.PropertyImage{}).Where("property_id = ?", property.ID).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch images"})
+		return
+	}
+	if int(count)+len(reqBody.Images) > maxPropertyImages {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A property can have at most 30 images"})
+		return
+	}
+
+	uploads := make([]imageUpload, 0, len(reqBody.Images))
+	expiresAt := time.Now().Add(imageUploadURLExpiry)
+	for i, image := range reqBody.Images {
+		key, err := utils.NewImageKey(property.ImagePrefix, int(count)+i, image.Filename)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload URL"})
+			return
+		}
+		url, headers, err := presigner.PresignPut(c.Request.Context(), key, image.ContentType, image.Size, imageUploadURLExpiry)
+		if err != nil {
+			log.Printf("Failed to presign upload of %s: %v", key, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload URL"})
+			return
+		}
+		uploads = append(uploads, imageUpload{Key: key, URL: url, Method: http.MethodPut, Headers: headers, ExpiresAt: expiresAt})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"uploads": uploads})
+}
+
+// ConfirmPropertyImageUploads processes directly uploaded images like any other upload and
+// adds them to the property. The uploaded files are removed once the images are saved.
+func (p *PropertiesHandler) ConfirmPropertyImageUploads(c *gin.Context) {
+	property, ok := p.findModifiableProperty(c)
+	if !ok {
+		return
+	}
+
+	var reqBody struct {
+		Keys []string `json:"keys" binding:"required,min=1"`
+	}
+	if err := c.BindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	keys := uniqueStrings(reqBody.Keys)
+
+	existing, err := p.propertyImages(p.DB, property.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch images"})
+		return
+	}
+	if len(existing)+len(keys) > maxPropertyImages {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A property can have at most 30 images"})
+		return
+	}
+	recorded := make(map[string]bool, len(existing))
+	for _, image := range existing {
+		for _, key := range image.StorageKeys() {
+			recorded[key] = true
+		}
+	}
+	for _, key := range keys {
+		// Keys outside the prefix belong to other properties
+		if !strings.HasPrefix(key, property.ImagePrefix+"/") || recorded[key] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s is not an upload of this property", key)})
+			return
+		}
+	}
+
+	uploaded, success := utils.ProcessStoredImages(c, p.Storage, property.ImagePrefix, keys)
+	if !success {
+		return
+	}
+
+	images := newPropertyImages(property.ID, uploaded, len(existing))
+	if len(existing) == 0 {
+		images[0].IsCover = true
+	}
+	if err := p.DB.Create(&images).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save images"})
+		p.cleanupFiles(uploadedKeys(uploaded))
+		return
+	}
+	// The uploaded files may still hold metadata, only the processed copies are kept
+	p.cleanupFiles(keys)
+
+	p.respondWithImages(c, http.StatusCreated, property, "Images added successfully")
+}
+
+// signImageURLs fills in short lived download URLs so the bucket can stay private. They
+// are added right before responding and never cached, since they expire.
+func (p *PropertiesHandler) signImageURLs(ctx context.Context, images []response.PropertyImage) {
+	presigner, ok := storage.PresignerOf(p.Storage)
+	if !ok {
+		return
+	}
+	for i := range images {
+		url, err := presigner.PresignGet(ctx, images[i].Key, imageDownloadURLExpiry)
+		if err != nil {
+			log.Printf("Failed to presign download of %s: %v", images[i].Key, err)
+			continue
+		}
+		images[i].URL = url
+		for name, rendition := range images[i].Renditions {
+			url, err := presigner.PresignGet(ctx, rendition.Key, imageDownloadURLExpiry)
+			if err != nil {
+				log.Printf("Failed to presign download of %s: %v", rendition.Key, err)
+				continue
+			}
+			rendition.URL = url
+			images[i].Renditions[name] = rendition
+		}
+	}
+}
+
+func (p *PropertiesHandler) signPropertyImageURLs(ctx context.Context, properties ...response.Property) {
+	for _, property := range properties {
+		p.signImageURLs(ctx, property.Images)
+	}
+}
@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang-test/models"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const mergePatchContentType = "application/merge-patch+json"
+
+// propertyPatchField is a field a merge patch may change
+type propertyPatchField struct {
+	column string
+	// dest points at the field of the property the value is decoded into
+	dest func(property *models.Property) interface{}
+	// nullable fields are cleared by null, the others can't be removed
+	nullable bool
+}
+
+// editablePropertyFields are the fields a merge patch may change, keyed by their JSON name.
+// Owner, status, images and timestamps are managed elsewhere.
+var editablePropertyFields = map[string]propertyPatchField{
+	"name":               {column: "name", dest: func(p *models.Property) interface{} { return &p.Name }},
+	"description":        {column: "description", dest: func(p *models.Property) interface{} { return &p.Description }},
+	"price":              {column: "price", dest: func(p *models.Property) interface{} { return &p.Price }},
+	"listingMode":        {column: "listing_mode", dest: func(p *models.Property) interface{} { return &p.ListingMode }},
+	"location":           {column: "location", dest: func(p *models.Property) interface{} { return &p.Location }},
+	"latitude":           {column: "latitude", dest: func(p *models.Property) interface{} { return &p.Latitude }, nullable: true},
+	"longitude":          {column: "longitude", dest: func(p *models.Property) interface{} { return &p.Longitude }, nullable: true},
+	"bedrooms":           {column: "bedrooms", dest: func(p *models.Property) interface{} { return &p.Bedrooms }, nullable: true},
+	"bathrooms":          {column: "bathrooms", dest: func(p *models.Property) interface{} { return &p.Bathrooms }, nullable: true},
+	"area":               {column: "area", dest: func(p *models.Property) interface{} { return &p.Area }, nullable: true},
+	"floor":              {column: "floor", dest: func(p *models.Property) interface{} { return &p.Floor }, nullable: true},
+	"yearBuilt":          {column: "year_built", dest: func(p *models.Property) interface{} { return &p.YearBuilt }, nullable: true},
+	"propertyTypeId":     {column: "property_type_id", dest: func(p *models.Property) interface{} { return &p.PropertyTypeID }},
+	"propertyCategoryId": {column: "property_category_id", dest: func(p *models.Property) interface{} { return &p.PropertyCategoryID }},
+}
+
+// PatchProperty applies a JSON Merge Patch (RFC 7396) to a property. Only the editable
+// fields may be given, null clears optional fields and zero values are stored as given.
+// "amenities" replaces the amenities by name and "version" is the version the patch
+// was made against, unless If-Match is sent.
+func (p *PropertiesHandler) PatchProperty(c *gin.Context) {
+	if c.ContentType() != mergePatchContentType {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Content-Type must be " + mergePatchContentType})
+		return
+	}
+
+	var property models.Property
+	if err := p.DB.First(&property, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Property not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch property"})
+		return
+	}
+	if !p.canModifyProperty(c, property.OwnerID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to update this property"})
+		return
+	}
+
+	var patch map[string]json.RawMessage
+	if err := json.NewDecoder(c.Request.Body).Decode(&patch); err != nil || patch == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Patch must be a JSON object"})
+		return
+	}
+
+	// The patch is applied to a copy, so the result is validated as a whole
+	patched := property
+	updates, err := applyPropertyPatch(&patched, patch)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validatePatchedProperty(&patched); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := p.validatePropertyReferences(patched); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// null clears the amenities, as it would any other list
+	var amenities []models.Amenity
+	if raw, ok := patch["amenities"]; ok {
+		var names []string
+		if err := json.Unmarshal(raw, &names); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "amenities must be a list of names"})
+			return
+		}
+		if amenities, err = p.findAmenities(parseAmenityNames(strings.Join(names, ","))); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var version uint
+	if raw, ok := patch["version"]; ok {
+		if err := json.Unmarshal(raw, &version); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "version must be a positive number"})
+			return
+		}
+	}
+	versions, ok := propertyPrecondition(c, property.ID, version)
+	if !ok {
+		c.JSON(http.StatusPreconditionRequired, gin.H{"error": "If-Match header or version is required"})
+		return
+	}
+
+	p.saveProperty(c, property, patched, updates, versions, amenities)
+}
+
+// applyPropertyPatch decodes the patched fields into the property and returns the column
+// values to store. Unknown and read-only fields are rejected.
+func applyPropertyPatch(property *models.Property, patch map[string]json.RawMessage) (map[string]interface{}, error) {
+	var rejected []string
+	updates := map[string]interface{}{}
+	for name, raw := range patch {
+		if name == "amenities" || name == "version" {
+			continue
+		}
+		field, ok := editablePropertyFields[name]
+		if !ok {
+			rejected = append(rejected, name)
+			continue
+		}
+		if string(raw) == "null" && !field.nullable {
+			return nil, fmt.Errorf("%s can't be removed", name)
+		}
+		// Decoding into a pointer field resets it first, so null clears it
+		dest := field.dest(property)
+		reflect.ValueOf(dest).Elem().SetZero()
+		if err := json.Unmarshal(raw, dest); err != nil {
+			return nil, fmt.Errorf("invalid value for %s", name)
+		}
+		updates[field.column] = reflect.ValueOf(dest).Elem().Interface()
+	}
+	if len(rejected) > 0 {
+		sort.Strings(rejected)
+		return nil, fmt.Errorf("fields can't be changed: %s", strings.Join(rejected, ", "))
+	}
+	return updates, nil
+}
+
+// validatePatchedProperty checks a property after the patch was applied
+func validatePatchedProperty(property *models.Property) error {
+	for field, value := range map[string]string{"name": property.Name, "description": property.Description, "location": property.Location, "listingMode": property.ListingMode} {
+		if strings.TrimSpace(value) == "" {
+			return fmt.Errorf("%s must not be empty", field)
+		}
+	}
+	if property.Price < 0 {
+		return fmt.Errorf("price must not be negative")
+	}
+	if property.PropertyTypeID == 0 || property.PropertyCategoryID == 0 {
+		return fmt.Errorf("propertyTypeId and propertyCategoryId are required")
+	}
+	return validatePropertyAttributes(property)
+}
@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"errors"
+	"golang-test/api/response"
+	"golang-test/models"
+	"golang-test/utils"
+	"io"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// PublishProperty submits a draft for review
+func (p *PropertiesHandler) PublishProperty(c *gin.Context) {
+	p.transitionProperty(c, []string{models.PropertyStatusDraft}, models.PropertyStatusPendingReview, "Property submitted for review")
+}
+
+// WithdrawProperty takes a listing off the market
+func (p *PropertiesHandler) WithdrawProperty(c *gin.Context) {
+	p.transitionProperty(c, nil, models.PropertyStatusWithdrawn, "Property withdrawn")
+}
+
+// RelistProperty submits a withdrawn or rented property for review again
+func (p *PropertiesHandler) RelistProperty(c *gin.Context) {
+	p.transitionProperty(c, []string{models.PropertyStatusWithdrawn, models.PropertyStatusRented}, models.PropertyStatusPendingReview, "Property submitted for review")
+}
+
+// ReviewProperty approves a listing pending review, or sends it back to its owner as a draft
+func (p *PropertiesHandler) ReviewProperty(c *gin.Context) {
+	var reqBody struct {
+		Approve *bool  `json:"approve" binding:"required"`
+		Note    string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "approve is required"})
+		return
+	}
+	if *reqBody.Approve {
+		p.changePropertyStatusAs(c, nil, models.PropertyStatusAvailable, reqBody.Note, "Property approved")
+		return
+	}
+	p.changePropertyStatusAs(c, nil, models.PropertyStatusDraft, reqBody.Note, "Property rejected")
+}
+
+// GetPropertyStatusHistory lists the status changes of a property, oldest first
+func (p *PropertiesHandler) GetPropertyStatusHistory(c *gin.Context) {
+	property, ok := p.findModifiableProperty(c)
+	if !ok {
+		return
+	}
+	var changes []models.PropertyStatusChange
+	if err := p.DB.Where("property_id = ?", property.ID).Order("created_at, id").Find(&changes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch status history"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": property.Status, "history": response.NewPropertyStatusChanges(changes)})
+}
+
+// transitionProperty moves the property to the status, the request may carry a note
+func (p *PropertiesHandler) transitionProperty(c *gin.Context, from []string, to, message string) {
+	var reqBody struct {
+		Note string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&reqBody); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	p.changePropertyStatusAs(c, from, to, reqBody.Note, message)
+}
+
+// changePropertyStatusAs moves the property to the status on behalf of the authenticated
+// user and responds with the updated property. Unless from is nil the property has to be
+// in one of its states, even if the move would be allowed from the current one.
+func (p *PropertiesHandler) changePropertyStatusAs(c *gin.Context, from []string, to, note, message string) {
+	var property models.Property
+	if err := p.DB.First(&property, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Property not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch property"})
+		return
+	}
+	if from != nil && !slices.Contains(from, property.Status) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Property can't move from " + property.Status + " to " + to})
+		return
+	}
+	actors := p.propertyActors(c, property)
+	if len(actors) == 0 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to update this property"})
+		return
+	}
+
+	userID := c.GetUint("userId")
+	previous := property
+	err := p.DB.Transaction(func(tx *gorm.DB) error {
+		return models.ChangePropertyStatus(tx, &property, to, actors, &userID, note)
+	})
+	switch {
+	case errors.Is(err, models.ErrStatusTransitionForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not allowed to move the property from " + previous.Status + " to " + to})
+		return
+	case errors.Is(err, models.ErrInvalidStatusTransition), errors.Is(err, models.ErrPropertyStatusChanged):
+		c.JSON(http.StatusConflict, gin.H{"error": "Property can't move from " + previous.Status + " to " + to})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update property status"})
+		return
+	}
+	p.invalidatePropertyCache(previous)
+
+	p.respondWithCurrentProperty(c, http.StatusOK, property.ID, gin.H{"message": message})
+}
+
+// propertyActors returns the roles the authenticated user may change the property's
+// status in
+func (p *PropertiesHandler) propertyActors(c *gin.Context, property models.Property) []models.PropertyActor {
+	var actors []models.PropertyActor
+	if p.canModifyProperty(c, property.OwnerID) {
+		actors = append(actors, models.PropertyActorOwner)
+	}
+	if utils.HasPermission(c, models.PermissionPropertyReview) {
+		actors = append(actors, models.PropertyActorReviewer)
+	}
+	return actors
+}
+
+// canViewProperty reports whether the authenticated user may see the property, listings
+// that aren't public are only shown to their owner and staff
+func (p *PropertiesHandler) canViewProperty(c *gin.Context, status string, ownerID uint) bool {
+	return models.IsPublicPropertyStatus(status) || p.canSeeAllListings(c) || c.GetUint("userId") == ownerID
+}
+
+// canSeeAllListings reports whether the authenticated user sees listings in every state
+func (p *PropertiesHandler) canSeeAllListings(c *gin.Context) bool {
+	return utils.HasPermission(c, models.PermissionPropertyManageAny) || utils.HasPermission(c, models.PermissionPropertyReview)
+}
@@ -1,7 +1,9 @@
 package handler
 
 import (
+	"golang-test/api/response"
 	"golang-test/models"
+	"golang-test/utils"
 	"net/http"
 	"strings"
 
@@ -34,5 +36,5 @@ func (p *PropertyTypeHandler) CreateType(c *gin.Context) {
 func (p *PropertyTypeHandler) GetTypes(c *gin.Context) {
 	var propertyTypes []models.PropertyType
 	p.DB.Model(models.PropertyType{}).Find(&propertyTypes)
-	c.JSON(http.StatusOK, gin.H{"categories": propertyTypes})
+	utils.JSONWithETag(c, gin.H{"categories": response.NewPropertyTypes(propertyTypes)})
 }
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"golang-test/models"
 	"net/http"
 	"strings"
@@ -37,3 +38,155 @@ func (h *RoleHandler) CreateRole(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Role created successfully", "data": role})
 }
+
+// GetRoles lists every role with its permissions
+func (h *RoleHandler) GetRoles(c *gin.Context) {
+	var roles []models.Role
+	if err := h.DB.Preload("Permissions").Find(&roles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch roles"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"roles": roles})
+}
+
+// GetRoleByID retrieves a single role with its permissions
+func (h *RoleHandler) GetRoleByID(c *gin.Context) {
+	role, ok := h.findRole(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"role": role})
+}
+
+// GetPermissions lists every permission that can be granted to a role
+func (h *RoleHandler) GetPermissions(c *gin.Context) {
+	var permissions []models.Permission
+	if err := h.DB.Order("name").Find(&permissions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch permissions"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"permissions": permissions})
+}
+
+// SetRolePermissions replaces the permissions of a role
+func (h *RoleHandler) SetRolePermissions(c *gin.Context) {
+	role, permissions, ok := h.bindRolePermissions(c)
+	if !ok {
+		return
+	}
+	if err := h.DB.Model(&role).Association("Permissions").Replace(permissions); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role permissions"})
+		return
+	}
+	h.respondWithRole(c, role.ID, "Role permissions updated successfully")
+}
+
+// AddRolePermissions grants additional permissions to a role
+func (h *RoleHandler) AddRolePermissions(c *gin.Context) {
+	role, permissions, ok := h.bindRolePermissions(c)
+	if !ok {
+		return
+	}
+	if err := h.DB.Model(&role).Association("Permissions").Append(permissions); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role permissions"})
+		return
+	}
+	h.respondWithRole(c, role.ID, "Role permissions updated successfully")
+}
+
+// RemoveRolePermission revokes a single permission from a role
+func (h *RoleHandler) RemoveRolePermission(c *gin.Context) {
+	role, ok := h.findRole(c)
+	if !ok {
+		return
+	}
+	var permission models.Permission
+	if err := h.DB.Where("name = ?", c.Param("permission")).First(&permission).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "permission not found"})
+		return
+	}
+	if err := h.DB.Model(&role).Association("Permissions").Delete(&permission); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role permissions"})
+		return
+	}
+	h.respondWithRole(c, role.ID, "Role permission removed successfully")
+}
+
+// UserHasPermission reports whether the user's current role has been granted the named permission
+func (h *RoleHandler) UserHasPermission(userID uint, permission string) bool {
+	var count int64
+	err := h.DB.Table("users").
+		Joins("JOIN role_permissions ON role_permissions.role_id = users.role_id").
+		Joins("JOIN permissions ON permissions.id = role_permissions.permission_id").
+		Where("users.id = ? AND users.deleted_at IS NULL", userID).
+		Where("permissions.name = ? AND permissions.deleted_at IS NULL", permission).
+		Count(&count).Error
+	if err != nil {
+		return false
+	}
+	return count > 0
+}
+
+// findRole loads the role from the id path parameter, writing the error response if it can't
+func (h *RoleHandler) findRole(c *gin.Context) (models.Role, bool) {
+	var role models.Role
+	if err := h.DB.Preload("Permissions").First(&role, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "role not found"})
+			return role, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch role"})
+		return role, false
+	}
+	return role, true
+}
+
+// bindRolePermissions loads the role and the permissions named in the request body
+func (h *RoleHandler) bindRolePermissions(c *gin.Context) (models.Role, []models.Permission, bool) {
+	var reqBody struct {
+		Permissions []string `json:"permissions"`
+	}
+	if err := c.BindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return models.Role{}, nil, false
+	}
+	role, ok := h.findRole(c)
+	if !ok {
+		return role, nil, false
+	}
+
+	permissions := []models.Permission{}
+	if len(reqBody.Permissions) > 0 {
+		if err := h.DB.Where("name IN ?", reqBody.Permissions).Find(&permissions).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch permissions"})
+			return role, nil, false
+		}
+	}
+	// Every requested permission must exist
+	if len(permissions) != len(uniqueStrings(reqBody.Permissions)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown permission"})
+		return role, nil, false
+	}
+	return role, permissions, true
+}
+
+func (h *RoleHandler) respondWithRole(c *gin.Context, roleID uint, message string) {
+	var role models.Role
+	if err := h.DB.Preload("Permissions").First(&role, roleID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch role"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message, "data": role})
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	var unique []string
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
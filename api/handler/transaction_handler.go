@@ -1,23 +1,36 @@
 package handler
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
+	"golang-test/api/response"
+	"golang-test/cache"
 	"golang-test/models"
+	"golang-test/queue"
+	"golang-test/utils"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
 type TransactionHandler struct {
-	DB *gorm.DB
+	DB    *gorm.DB
+	Cache cache.Cache
+	Jobs  queue.Queue
 }
 
-// CreateTransaction handles buying or renting a property
+// CreateTransaction buys or rents a property at its listed price. The property is under
+// offer until the owner completes the transaction.
 func (t *TransactionHandler) CreateTransaction(c *gin.Context) {
 	var requestBody struct {
-		PropertyID uint `json:"propertyId" binding:"required"`
+		PropertyID uint   `json:"propertyId" binding:"required"`
+		Type       string `json:"type"` // Only needed for properties offered both ways
 	}
 	
 	if err := c.BindJSON(&requestBody); err != nil {
@@ -40,9 +53,10 @@ func (t *TransactionHandler) CreateTransaction(c *gin.Context) {
 		}
 	}()
 	
-	// 1. Get the property with its owner
-	var property models.Property
-	if err := tx.Preload("PropertyType").First(&property, requestBody.PropertyID).Error; err != nil {
+	// 1. Get the property and lock it, a concurrent request for it waits until this one
+	// is done and then sees the new status
+	property, err := lockProperty(tx, requestBody.PropertyID)
+	if err != nil {
 		tx.Rollback()
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Property not found"})
@@ -53,7 +67,7 @@ func (t *TransactionHandler) CreateTransaction(c *gin.Context) {
 	}
 	
 	// 2. Check if property is available
-	if property.Status != "available" {
+	if property.Status != models.PropertyStatusAvailable {
 		tx.Rollback()
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Property is not available"})
 		return
@@ -66,29 +80,20 @@ func (t *TransactionHandler) CreateTransaction(c *gin.Context) {
 		return
 	}
 	
-	// 4. Create transaction record
-	transaction := models.Transaction{
-		ClientID:   clientID.(uint),
-		OwnerID:    property.OwnerID,
-		PropertyID: property.ID,
-		Type:       property.PropertyType.Name, // "rent" or "buy"
-	}
-	
-	if err := tx.Create(&transaction).Error; err != nil {
+	// 4. Check that the property is offered the way the client asked for
+	transactionType, err := chooseTransactionType(property, requestBody.Type)
+	if err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create transaction"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 	
-	// 5. Update property status
-	newStatus := "sold"
-	if property.PropertyType.Name == "rent" {
-		newStatus = "rented"
-	}
-	
-	if err := tx.Model(&property).Update("status", newStatus).Error; err != nil {
+	// 5. Create the transaction and put the property under offer
+	previous := property
+	transaction, err := openTransaction(tx, &property, clientID.(uint), transactionType, property.Price, nil)
+	if err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update property status"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create transaction"})
 		return
 	}
 	
@@ -98,10 +103,187 @@ func (t *TransactionHandler) CreateTransaction(c *gin.Context) {
 		return
 	}
 	
+	// Listings show the status, so the ones with this property are stale now
+	invalidateCache(t.Cache, propertyTags(previous)...)
+	
 	c.JSON(http.StatusCreated, gin.H{
-		"message": "Property " + newStatus + " successfully",
-		"transaction": transaction,
+		"message": "Property is under offer until the owner completes the transaction",
+		"transaction": response.NewTransaction(transaction),
+	})
+}
+
+// CompleteTransaction marks the property as sold or rented, only the owner can complete
+// a transaction. Completing a rental needs the lease terms, the lease ends on its own at
+// the end date.
+func (t *TransactionHandler) CompleteTransaction(c *gin.Context) {
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	
+	// Sales are completed without terms, an empty body or null sends none
+	var terms *leaseTerms
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && string(trimmed) != "null" {
+		var sent leaseTerms
+		if err := binding.JSON.BindBody(body, &sent); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+		if err := sent.validate(time.Now()); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		terms = &sent
+	}
+	t.closeTransaction(c, models.TransactionStatusCompleted, terms)
+}
+
+// CancelTransaction puts the property back on the market, either party can cancel
+func (t *TransactionHandler) CancelTransaction(c *gin.Context) {
+	t.closeTransaction(c, models.TransactionStatusCancelled, nil)
+}
+
+// closeTransaction moves a pending transaction and its property to their final state,
+// completed rentals start a lease with the terms
+func (t *TransactionHandler) closeTransaction(c *gin.Context, status string, terms *leaseTerms) {
+	userID := c.GetUint("userId")
+	manageAny := utils.HasPermission(c, models.PermissionPropertyManageAny)
+	
+	var transaction models.Transaction
+	var previous models.Property
+	var lease *models.Lease
+	err := t.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&transaction, c.Param("id")).Error; err != nil {
+			return err
+		}
+		allowed := userID == transaction.OwnerID || manageAny
+		if status == models.TransactionStatusCancelled {
+			allowed = allowed || userID == transaction.ClientID
+		}
+		if !allowed {
+			return errTransactionForbidden
+		}
+		if transaction.Status != models.TransactionStatusPending {
+			return errTransactionClosed
+		}
+		
+		property, err := lockProperty(tx, transaction.PropertyID)
+		if err != nil {
+			return err
+		}
+		previous = property
+		next := models.PropertyStatusAvailable
+		if status == models.TransactionStatusCompleted {
+			next = models.PropertyStatusSold
+			if transaction.Type == models.TransactionTypeRent {
+				next = models.PropertyStatusRented
+				if terms == nil {
+					return errLeaseTermsRequired
+				}
+				created, err := newLease(tx, transaction, *terms)
+				if err != nil {
+					return err
+				}
+				lease = &created
+			}
+		}
+		// A property withdrawn meanwhile stays withdrawn when the transaction is cancelled
+		if status == models.TransactionStatusCompleted || property.Status == models.PropertyStatusUnderOffer {
+			note := fmt.Sprintf("transaction %d %s", transaction.ID, status)
+			if err := models.ChangePropertyStatus(tx, &property, next, []models.PropertyActor{models.PropertyActorSystem}, &userID, note); err != nil {
+				return err
+			}
+		}
+		
+		now := time.Now()
+		transaction.Status = status
+		transaction.ClosedAt = &now
+		return tx.Model(&transaction).Select("status", "closed_at").Updates(&transaction).Error
 	})
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		return
+	case errors.Is(err, errTransactionForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to " + transactionActions[status] + " this transaction"})
+		return
+	case errors.Is(err, errTransactionClosed):
+		c.JSON(http.StatusConflict, gin.H{"error": "Transaction is not pending"})
+		return
+	case errors.Is(err, errLeaseTermsRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "startDate and endDate of the lease are required to complete a rental"})
+		return
+	case errors.Is(err, models.ErrInvalidStatusTransition):
+		c.JSON(http.StatusConflict, gin.H{"error": "Property is no longer under offer"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update transaction"})
+		return
+	}
+	
+	invalidateCache(t.Cache, propertyTags(previous)...)
+	
+	body := gin.H{
+		"message": "Transaction " + status,
+		"transaction": response.NewTransaction(transaction),
+	}
+	if lease != nil {
+		scheduleLeaseEnd(c.Request.Context(), t.Jobs, *lease)
+		scheduleRentCharges(c.Request.Context(), t.Jobs, lease.Installments)
+		body["lease"] = response.NewLease(*lease)
+	}
+	c.JSON(http.StatusOK, body)
+}
+
+var (
+	errTransactionForbidden = errors.New("not a party of the transaction")
+	errTransactionClosed    = errors.New("transaction is not pending")
+)
+
+// transactionActions names the action that closes a transaction with the status
+var transactionActions = map[string]string{
+	models.TransactionStatusCompleted: "complete",
+	models.TransactionStatusCancelled: "cancel",
+}
+
+// chooseTransactionType checks the requested transaction type against the property's
+// listing mode, it can be left out when the property is only offered one way
+func chooseTransactionType(property models.Property, requested string) (string, error) {
+	if allowed := property.TransactionTypes(); requested == "" && len(allowed) == 1 {
+		return allowed[0], nil
+	}
+	if !property.AllowsTransactionType(requested) {
+		return "", fmt.Errorf("type must be one of %s", strings.Join(property.TransactionTypes(), ", "))
+	}
+	return requested, nil
+}
+
+// lockProperty loads the property and locks its row until the transaction ends
+func lockProperty(tx *gorm.DB, id uint) (models.Property, error) {
+	var property models.Property
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&property, id).Error
+	return property, err
+}
+
+// openTransaction records a pending transaction for the property and puts the property
+// under offer. The property has to be locked and available.
+func openTransaction(tx *gorm.DB, property *models.Property, clientID uint, transactionType string, price float32, offerID *uint) (models.Transaction, error) {
+	transaction := models.Transaction{
+		ClientID:   clientID,
+		OwnerID:    property.OwnerID,
+		PropertyID: property.ID,
+		Type:       transactionType,
+		Price:      price,
+		Status:     models.TransactionStatusPending,
+		OfferID:    offerID,
+	}
+	if err := tx.Create(&transaction).Error; err != nil {
+		return transaction, err
+	}
+	note := fmt.Sprintf("transaction %d", transaction.ID)
+	err := models.ChangePropertyStatus(tx, property, models.PropertyStatusUnderOffer, []models.PropertyActor{models.PropertyActorSystem}, &clientID, note)
+	return transaction, err
 }
 
 // GetUserTransactions gets all transactions for the current user
@@ -121,5 +303,5 @@ func (t *TransactionHandler) GetUserTransactions(c *gin.Context) {
 		return
 	}
 	
-	c.JSON(http.StatusOK, gin.H{"transactions": transactions})
+	c.JSON(http.StatusOK, gin.H{"transactions": response.NewTransactions(transactions)})
 }
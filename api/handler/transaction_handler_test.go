@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang-test/models"
+	"golang-test/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTransactionDB holds a pending transaction of the given type for property 1, which
+// is under offer. The owner is user 1, the client user 2.
+func newTransactionDB(t *testing.T, transactionType string) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard, DisableForeignKeyConstraintWhenMigrating: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Created without the tables they refer to
+	if err := db.Migrator().CreateTable(&models.Transaction{}, &models.PropertyStatusChange{}); err != nil {
+		t.Fatal(err)
+	}
+	createPropertiesTable(t, db)
+	if err := db.Exec("INSERT INTO properties (id, status, version, owner_id) VALUES (1, ?, 1, 1)", models.PropertyStatusUnderOffer).Error; err != nil {
+		t.Fatal(err)
+	}
+	transaction := models.Transaction{ClientID: 2, OwnerID: 1, PropertyID: 1, Type: transactionType, Status: models.TransactionStatusPending}
+	if err := db.Create(&transaction).Error; err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// createPropertiesTable creates the columns of properties the handlers use, the postgres
+// search vector and its index don't exist in sqlite
+func createPropertiesTable(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	if err := db.Exec(`CREATE TABLE properties (id integer PRIMARY KEY, created_at datetime, updated_at datetime,
+		deleted_at datetime, name text, status text, version integer, listing_mode text, price real, owner_id integer)`).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func completeTransaction(db *gorm.DB, body string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("userId", uint(1)) })
+	router.POST("/transactions/:id/complete", (&TransactionHandler{DB: db}).CompleteTransaction)
+
+	request := httptest.NewRequest(http.MethodPost, "/transactions/1/complete", strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestCompleteTransactionWithoutTerms(t *testing.T) {
+	previous := utils.UserHasPermission
+	utils.UserHasPermission = func(uint, string) bool { return false }
+	t.Cleanup(func() { utils.UserHasPermission = previous })
+
+	tests := []struct {
+		name            string
+		transactionType string
+		body            string
+		want            int
+		wantStatus      string
+	}{
+		{"sale with an empty body", models.TransactionTypeBuy, "", http.StatusOK, models.TransactionStatusCompleted},
+		{"sale with null", models.TransactionTypeBuy, "null", http.StatusOK, models.TransactionStatusCompleted},
+		{"sale with whitespace", models.TransactionTypeBuy, " \n", http.StatusOK, models.TransactionStatusCompleted},
+		{"rental with an empty body", models.TransactionTypeRent, "", http.StatusBadRequest, models.TransactionStatusPending},
+		{"rental with null", models.TransactionTypeRent, "null", http.StatusBadRequest, models.TransactionStatusPending},
+		{"rental without dates", models.TransactionTypeRent, `{"deposit":100}`, http.StatusBadRequest, models.TransactionStatusPending},
+		{"malformed terms", models.TransactionTypeRent, `{"startDate":`, http.StatusBadRequest, models.TransactionStatusPending},
+	}
+	for _, tt := range tests {
+		db := newTransactionDB(t, tt.transactionType)
+		response := completeTransaction(db, tt.body)
+		if response.Code != tt.want {
+			t.Errorf("%s: got %d %s, want %d", tt.name, response.Code, response.Body, tt.want)
+		}
+		var transaction models.Transaction
+		if err := db.First(&transaction, 1).Error; err != nil {
+			t.Fatal(err)
+		}
+		if transaction.Status != tt.wantStatus {
+			t.Errorf("%s: the transaction is %s, want %s", tt.name, transaction.Status, tt.wantStatus)
+		}
+	}
+}
@@ -2,7 +2,7 @@ package handler
 
 import (
 	"errors"
-	"fmt"
+	"golang-test/api/response"
 	"golang-test/models"
 	"golang-test/utils"
 	"net/http"
@@ -21,7 +21,6 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 		Email    string `json:"email"`
 		Password string `json:"password"`
 		Name     string `json:"name"`
-		RoleID   uint   `json:"roleId"`
 	}
 	var reqBody reqBodyFields
 	if err := c.BindJSON(&reqBody); err != nil {
@@ -35,11 +34,10 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 		c.JSON(http.StatusConflict, gin.H{"error": "email already exist"})
 		return
 	}
-	// Check if role exist
+	// Users signing up always get the default role, other roles are assigned through SetUserRole
 	var role models.Role
-	h.DB.Model(models.Role{}).First(&role)
-	if role.ID == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "role doesn't exist"})
+	if err := h.DB.Where("name = ?", models.DefaultRoleName).First(&role).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "An error has occurred while creating your account"})
 		return
 	}
 	hashedPassword, err := utils.HashPassword(reqBody.Password)
@@ -50,12 +48,12 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 	user.Password = hashedPassword
 	user.Email = reqBody.Email
 	user.Name = reqBody.Name
-	user.RoleID = reqBody.RoleID
+	user.RoleID = role.ID
 	if err := h.DB.Create(&user).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "User created successfully", "data": user.Serialize()})
+	c.JSON(http.StatusOK, gin.H{"message": "User created successfully", "data": response.NewUser(user)})
 }
 
 func (h *UserHandler) GetAllUsers(c *gin.Context) {
@@ -64,18 +62,7 @@ func (h *UserHandler) GetAllUsers(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
 		return
 	}
-	var concurrency int
-	if len(users) > 5 && len(users) < 100 {
-		concurrency = 10
-	} else if len(users) > 100 {
-		concurrency = 20
-	} else {
-		concurrency = 2
-	}
-	fmt.Println(concurrency)
-	serializedUsersInit := utils.Pool{Concurrency: concurrency}
-	serializedUsers := serializedUsersInit.Run(users)
-	c.JSON(http.StatusOK, gin.H{"users": serializedUsers})
+	c.JSON(http.StatusOK, gin.H{"users": response.NewUsers(users)})
 }
 
 func (p *UserHandler) GetUserById(c *gin.Context) {
@@ -91,5 +78,41 @@ func (p *UserHandler) GetUserById(c *gin.Context) {
 		return
 	}
 	
-	c.JSON(http.StatusOK, gin.H{"user": user.Serialize()})
+	c.JSON(http.StatusOK, gin.H{"user": response.NewUser(user)})
+}
+
+// SetUserRole assigns another role to the user. Permissions are checked against the
+// current role on every request, so the change applies to the user's existing tokens.
+func (h *UserHandler) SetUserRole(c *gin.Context) {
+	var reqBody struct {
+		RoleID uint `json:"roleId" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	var user models.User
+	if err := h.DB.First(&user, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+	var role models.Role
+	if err := h.DB.First(&role, reqBody.RoleID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "role doesn't exist"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch role"})
+		return
+	}
+	if err := h.DB.Model(&user).Update("role_id", role.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user role"})
+		return
+	}
+	user.RoleID = role.ID
+	c.JSON(http.StatusOK, gin.H{"message": "User role updated successfully", "data": response.NewUser(user)})
 }
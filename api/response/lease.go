@@ -0,0 +1,65 @@
+package response
+
+import (
+	"time"
+
+	"golang-test/models"
+)
+
+type Lease struct {
+	ID                uint       `json:"id"`
+	TransactionID     uint       `json:"transactionId"`
+	PropertyID        uint       `json:"propertyId"`
+	TenantID          uint       `json:"tenantId"`
+	OwnerID           uint       `json:"ownerId"`
+	StartDate         time.Time  `json:"startDate"`
+	EndDate           time.Time  `json:"endDate"`
+	MonthlyRent       float32    `json:"monthlyRent"`
+	Deposit           float32    `json:"deposit"`
+	RenewalMonths     int        `json:"renewalMonths"`
+	MaxRenewals       int        `json:"maxRenewals"`
+	Renewals          int        `json:"renewals"`
+	Renewable         bool       `json:"renewable"`
+	Status            string     `json:"status"`
+	TerminatedAt      *time.Time `json:"terminatedAt,omitempty"`
+	TerminatedByID    *uint      `json:"terminatedById,omitempty"`
+	TerminationReason string     `json:"terminationReason,omitempty"`
+	Property          *Property  `json:"property,omitempty"`
+	CreatedAt         time.Time  `json:"createdAt"`
+}
+
+func NewLease(l models.Lease) Lease {
+	lease := Lease{
+		ID:                l.ID,
+		TransactionID:     l.TransactionID,
+		PropertyID:        l.PropertyID,
+		TenantID:          l.TenantID,
+		OwnerID:           l.OwnerID,
+		StartDate:         l.StartDate,
+		EndDate:           l.EndDate,
+		MonthlyRent:       l.MonthlyRent,
+		Deposit:           l.Deposit,
+		RenewalMonths:     l.RenewalMonths,
+		MaxRenewals:       l.MaxRenewals,
+		Renewals:          l.Renewals,
+		Renewable:         l.Renewable(),
+		Status:            l.Status,
+		TerminatedAt:      l.TerminatedAt,
+		TerminatedByID:    l.TerminatedByID,
+		TerminationReason: l.TerminationReason,
+		CreatedAt:         l.CreatedAt,
+	}
+	if l.Property.ID != 0 {
+		property := NewProperty(l.Property)
+		lease.Property = &property
+	}
+	return lease
+}
+
+func NewLeases(leases []models.Lease) []Lease {
+	result := make([]Lease, 0, len(leases))
+	for _, l := range leases {
+		result = append(result, NewLease(l))
+	}
+	return result
+}
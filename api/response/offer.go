@@ -0,0 +1,59 @@
+package response
+
+import (
+	"time"
+
+	"golang-test/models"
+)
+
+type Offer struct {
+	ID           uint       `json:"id"`
+	PropertyID   uint       `json:"propertyId"`
+	ClientID     uint       `json:"clientId"`
+	OwnerID      uint       `json:"ownerId"`
+	ProposedByID uint       `json:"proposedById"`
+	CountersID   *uint      `json:"countersId,omitempty"`
+	Type         string     `json:"type"`
+	Price        float32    `json:"price"`
+	Terms        string     `json:"terms"`
+	Status       string     `json:"status"`
+	ExpiresAt    time.Time  `json:"expiresAt"`
+	RespondedAt  *time.Time `json:"respondedAt,omitempty"`
+	Property     *Property  `json:"property,omitempty"`
+	CreatedAt    time.Time  `json:"createdAt"`
+}
+
+func NewOffer(o models.Offer) Offer {
+	offer := Offer{
+		ID:           o.ID,
+		PropertyID:   o.PropertyID,
+		ClientID:     o.ClientID,
+		OwnerID:      o.OwnerID,
+		ProposedByID: o.ProposedByID,
+		CountersID:   o.CountersID,
+		Type:         o.Type,
+		Price:        o.Price,
+		Terms:        o.Terms,
+		Status:       o.Status,
+		ExpiresAt:    o.ExpiresAt,
+		RespondedAt:  o.RespondedAt,
+		CreatedAt:    o.CreatedAt,
+	}
+	// Offers that ran out before the expiry job got to them are shown as expired
+	if o.Status == models.OfferStatusPending && !o.Open(time.Now()) {
+		offer.Status = models.OfferStatusExpired
+	}
+	if o.Property.ID != 0 {
+		property := NewProperty(o.Property)
+		offer.Property = &property
+	}
+	return offer
+}
+
+func NewOffers(offers []models.Offer) []Offer {
+	result := make([]Offer, 0, len(offers))
+	for _, o := range offers {
+		result = append(result, NewOffer(o))
+	}
+	return result
+}
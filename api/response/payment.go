@@ -0,0 +1,123 @@
+package response
+
+import (
+	"time"
+
+	"golang-test/models"
+)
+
+// Amounts of payments, installments and the ledger are in cents
+
+type Payment struct {
+	ID            uint       `json:"id"`
+	LeaseID       uint       `json:"leaseId"`
+	PayerID       uint       `json:"payerId"`
+	RecordedByID  uint       `json:"recordedById"`
+	Amount        int64      `json:"amount"`
+	Refunded      int64      `json:"refunded"`
+	Method        string     `json:"method"`
+	Status        string     `json:"status"`
+	FailureReason string     `json:"failureReason,omitempty"`
+	SettledAt     *time.Time `json:"settledAt,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+}
+
+func NewPayment(p models.Payment) Payment {
+	return Payment{
+		ID:            p.ID,
+		LeaseID:       p.LeaseID,
+		PayerID:       p.PayerID,
+		RecordedByID:  p.RecordedByID,
+		Amount:        p.Amount,
+		Refunded:      p.Refunded,
+		Method:        p.Method,
+		Status:        p.Status,
+		FailureReason: p.FailureReason,
+		SettledAt:     p.SettledAt,
+		CreatedAt:     p.CreatedAt,
+	}
+}
+
+func NewPayments(payments []models.Payment) []Payment {
+	result := make([]Payment, 0, len(payments))
+	for _, p := range payments {
+		result = append(result, NewPayment(p))
+	}
+	return result
+}
+
+type LedgerEntry struct {
+	Account string `json:"account"`
+	Amount  int64  `json:"amount"`
+}
+
+type LedgerJournal struct {
+	ID            uint          `json:"id"`
+	Kind          string        `json:"kind"`
+	Description   string        `json:"description"`
+	InstallmentID *uint         `json:"installmentId,omitempty"`
+	PaymentID     *uint         `json:"paymentId,omitempty"`
+	Entries       []LedgerEntry `json:"entries"`
+	CreatedAt     time.Time     `json:"createdAt"`
+}
+
+func NewLedgerJournals(journals []models.LedgerJournal) []LedgerJournal {
+	result := make([]LedgerJournal, 0, len(journals))
+	for _, j := range journals {
+		journal := LedgerJournal{
+			ID:            j.ID,
+			Kind:          j.Kind,
+			Description:   j.Description,
+			InstallmentID: j.InstallmentID,
+			PaymentID:     j.PaymentID,
+			Entries:       make([]LedgerEntry, 0, len(j.Entries)),
+			CreatedAt:     j.CreatedAt,
+		}
+		for _, e := range j.Entries {
+			journal.Entries = append(journal.Entries, LedgerEntry{Account: e.Account, Amount: e.Amount})
+		}
+		result = append(result, journal)
+	}
+	return result
+}
+
+type RentInstallment struct {
+	ID        uint      `json:"id"`
+	LeaseID   uint      `json:"leaseId"`
+	Number    int       `json:"number"`
+	DueDate   time.Time `json:"dueDate"`
+	PeriodEnd time.Time `json:"periodEnd"`
+	Amount    int64     `json:"amount"`
+	Paid      int64     `json:"paid"`
+	Status    string    `json:"status"`
+	Overdue   bool      `json:"overdue"`
+}
+
+// NewRentInstallment shows the installment with the part of it the tenant paid, charged
+// installments that aren't paid in full after their due date are overdue
+func NewRentInstallment(i models.RentInstallment, paid int64, now time.Time) RentInstallment {
+	return RentInstallment{
+		ID:        i.ID,
+		LeaseID:   i.LeaseID,
+		Number:    i.Number,
+		DueDate:   i.DueDate,
+		PeriodEnd: i.PeriodEnd,
+		Amount:    i.Amount,
+		Paid:      paid,
+		Status:    i.Status,
+		Overdue:   i.Status == models.RentInstallmentStatusCharged && paid < i.Amount && i.DueDate.Before(now),
+	}
+}
+
+// LeaseBalance sums up the ledger of a lease. Owed is negative when the tenant paid in
+// advance.
+type LeaseBalance struct {
+	LeaseID      uint              `json:"leaseId"`
+	Currency     string            `json:"currency"`
+	Owed         int64             `json:"owed"`
+	Overdue      int64             `json:"overdue"`
+	DepositHeld  int64             `json:"depositHeld"`
+	RentCharged  int64             `json:"rentCharged"`
+	Collected    int64             `json:"collected"`
+	Installments []RentInstallment `json:"installments"`
+}
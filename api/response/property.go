@@ -0,0 +1,228 @@
+package response
+
+import (
+	"sort"
+	"time"
+
+	"golang-test/models"
+)
+
+type PropertyType struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+type PropertyCategory struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+type Amenity struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+type PropertyImage struct {
+	ID          uint   `json:"id"`
+	Key         string `json:"key"`
+	URL         string `json:"url,omitempty"`
+	ContentType string `json:"contentType"`
+	Size        int64  `json:"size"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Position    int    `json:"position"`
+	IsCover     bool   `json:"isCover"`
+
+	Renditions map[string]ImageRendition `json:"renditions,omitempty"`
+}
+
+type ImageRendition struct {
+	Key string `json:"key"`
+	URL string `json:"url,omitempty"`
+}
+
+type Property struct {
+	ID                 uint              `json:"id"`
+	Name               string            `json:"name"`
+	Description        string            `json:"description"`
+	Status             string            `json:"status"`
+	Version            uint              `json:"version"`
+	ListingMode        string            `json:"listingMode"`
+	Price              float32           `json:"price"`
+	Location           string            `json:"location"`
+	Latitude           *float64          `json:"latitude"`
+	Longitude          *float64          `json:"longitude"`
+	Bedrooms           *int              `json:"bedrooms"`
+	Bathrooms          *int              `json:"bathrooms"`
+	Area               *float64          `json:"area"`
+	Floor              *int              `json:"floor"`
+	YearBuilt          *int              `json:"yearBuilt"`
+	Amenities          []Amenity         `json:"amenities"`
+	OwnerID            uint              `json:"ownerId"`
+	Owner              *User             `json:"owner,omitempty"`
+	ImagePrefix        string            `json:"imagePrefix"`
+	Images             []PropertyImage   `json:"images"`
+	PropertyTypeID     uint              `json:"propertyTypeId"`
+	PropertyType       *PropertyType     `json:"propertyType,omitempty"`
+	PropertyCategoryID uint              `json:"propertyCategoryId"`
+	PropertyCategory   *PropertyCategory `json:"propertyCategory,omitempty"`
+	CreatedAt          time.Time         `json:"createdAt"`
+	UpdatedAt          time.Time         `json:"updatedAt"`
+	Rank               float64           `json:"rank,omitempty"`
+	Highlight          string            `json:"highlight,omitempty"`
+	DistanceKm         *float64          `json:"distanceKm,omitempty"`
+}
+
+func NewPropertyType(t models.PropertyType) PropertyType {
+	return PropertyType{ID: t.ID, Name: t.Name}
+}
+
+func NewPropertyTypes(types []models.PropertyType) []PropertyType {
+	result := make([]PropertyType, 0, len(types))
+	for _, t := range types {
+		result = append(result, NewPropertyType(t))
+	}
+	return result
+}
+
+func NewPropertyCategory(c models.PropertyCategory) PropertyCategory {
+	return PropertyCategory{ID: c.ID, Name: c.Name}
+}
+
+func NewPropertyCategories(categories []models.PropertyCategory) []PropertyCategory {
+	result := make([]PropertyCategory, 0, len(categories))
+	for _, c := range categories {
+		result = append(result, NewPropertyCategory(c))
+	}
+	return result
+}
+
+func NewAmenity(a models.Amenity) Amenity {
+	return Amenity{ID: a.ID, Name: a.Name}
+}
+
+func NewAmenities(amenities []models.Amenity) []Amenity {
+	result := make([]Amenity, 0, len(amenities))
+	for _, a := range amenities {
+		result = append(result, NewAmenity(a))
+	}
+	return result
+}
+
+func NewPropertyImage(i models.PropertyImage) PropertyImage {
+	return PropertyImage{
+		ID:          i.ID,
+		Key:         i.Key,
+		ContentType: i.ContentType,
+		Size:        i.Size,
+		Width:       i.Width,
+		Height:      i.Height,
+		Position:    i.Position,
+		IsCover:     i.IsCover,
+		Renditions:  NewImageRenditions(i.Renditions),
+	}
+}
+
+func NewImageRenditions(renditions map[string]string) map[string]ImageRendition {
+	if len(renditions) == 0 {
+		return nil
+	}
+	result := make(map[string]ImageRendition, len(renditions))
+	for name, key := range renditions {
+		result[name] = ImageRendition{Key: key}
+	}
+	return result
+}
+
+// NewPropertyImages serializes the images ordered by their position
+func NewPropertyImages(images []models.PropertyImage) []PropertyImage {
+	result := make([]PropertyImage, 0, len(images))
+	for _, i := range images {
+		result = append(result, NewPropertyImage(i))
+	}
+	sort.SliceStable(result, func(a, b int) bool { return result[a].Position < result[b].Position })
+	return result
+}
+
+func NewProperty(p models.Property) Property {
+	property := Property{
+		ID:                 p.ID,
+		Name:               p.Name,
+		Description:        p.Description,
+		Status:             p.Status,
+		Version:            p.Version,
+		ListingMode:        p.ListingMode,
+		Price:              p.Price,
+		Location:           p.Location,
+		Latitude:           p.Latitude,
+		Longitude:          p.Longitude,
+		Bedrooms:           p.Bedrooms,
+		Bathrooms:          p.Bathrooms,
+		Area:               p.Area,
+		Floor:              p.Floor,
+		YearBuilt:          p.YearBuilt,
+		Amenities:          NewAmenities(p.Amenities),
+		OwnerID:            p.OwnerID,
+		Owner:              newLoadedUser(p.Owner),
+		ImagePrefix:        p.ImagePrefix,
+		Images:             NewPropertyImages(p.Images),
+		PropertyTypeID:     p.PropertyTypeID,
+		PropertyCategoryID: p.PropertyCategoryID,
+		CreatedAt:          p.CreatedAt,
+		UpdatedAt:          p.UpdatedAt,
+	}
+	if p.PropertyType.ID != 0 {
+		propertyType := NewPropertyType(p.PropertyType)
+		property.PropertyType = &propertyType
+	}
+	if p.PropertyCategory.ID != 0 {
+		category := NewPropertyCategory(p.PropertyCategory)
+		property.PropertyCategory = &category
+	}
+	return property
+}
+
+func NewProperties(properties []models.Property) []Property {
+	result := make([]Property, 0, len(properties))
+	for _, p := range properties {
+		result = append(result, NewProperty(p))
+	}
+	return result
+}
+
+// PropertyPage is the envelope returned by the property listing
+type PropertyPage struct {
+	Properties []Property        `json:"properties"`
+	Total      int64             `json:"total"`
+	Page       int               `json:"page,omitempty"`
+	PageSize   int               `json:"pageSize"`
+	NextCursor string            `json:"nextCursor,omitempty"`
+	Links      map[string]string `json:"links"`
+	Source     string            `json:"source"`
+}
+
+type PropertyStatusChange struct {
+	ID        uint      `json:"id"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	ActorID   *uint     `json:"actorId"`
+	Actor     string    `json:"actor"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func NewPropertyStatusChanges(changes []models.PropertyStatusChange) []PropertyStatusChange {
+	result := make([]PropertyStatusChange, 0, len(changes))
+	for _, change := range changes {
+		result = append(result, PropertyStatusChange{
+			ID:        change.ID,
+			From:      change.From,
+			To:        change.To,
+			ActorID:   change.ActorID,
+			Actor:     string(change.Actor),
+			Note:      change.Note,
+			CreatedAt: change.CreatedAt,
+		})
+	}
+	return result
+}
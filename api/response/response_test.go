@@ -0,0 +1,97 @@
+package response
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"golang-test/models"
+)
+
+const passwordHash = "$2a$14$abcdefghijklmnopqrstuv"
+
+func testUser(id uint) models.User {
+	user := models.User{Name: "Test User", Email: "test@example.com", Password: passwordHash, RoleID: 2}
+	user.ID = id
+	return user
+}
+
+func testProperty() models.Property {
+	property := models.Property{
+		Name:               "Flat",
+		OwnerID:            1,
+		Owner:              testUser(1),
+		PropertyTypeID:     1,
+		PropertyType:       models.PropertyType{ID: 1, Name: "apartment"},
+		PropertyCategoryID: 1,
+		PropertyCategory:   models.PropertyCategory{ID: 1, Name: "residential"},
+	}
+	property.ID = 1
+	return property
+}
+
+// assertNoPassword fails when the JSON encoding of v contains a password field at any depth
+func assertNoPassword(t *testing.T, name string, v interface{}) {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("%s: failed to marshal: %v", name, err)
+	}
+	if strings.Contains(string(data), passwordHash) {
+		t.Errorf("%s: response contains the password hash: %s", name, data)
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("%s: failed to unmarshal: %v", name, err)
+	}
+	var walk func(path string, node interface{})
+	walk = func(path string, node interface{}) {
+		switch n := node.(type) {
+		case map[string]interface{}:
+			for key, value := range n {
+				if strings.EqualFold(key, "password") {
+					t.Errorf("%s: response contains a password field at %s.%s", name, path, key)
+				}
+				walk(path+"."+key, value)
+			}
+		case []interface{}:
+			for _, value := range n {
+				walk(path+"[]", value)
+			}
+		}
+	}
+	walk(name, decoded)
+}
+
+func TestResponsesNeverContainPassword(t *testing.T) {
+	property := testProperty()
+	transaction := models.Transaction{
+		ClientID:   2,
+		OwnerID:    1,
+		PropertyID: property.ID,
+		Type:       "sale",
+		Client:     testUser(2),
+		Owner:      testUser(1),
+		Property:   property,
+	}
+	transaction.ID = 1
+
+	assertNoPassword(t, "user", NewUser(testUser(1)))
+	assertNoPassword(t, "users", NewUsers([]models.User{testUser(1), testUser(2)}))
+	assertNoPassword(t, "property", NewProperty(property))
+	assertNoPassword(t, "properties", NewProperties([]models.Property{property}))
+	assertNoPassword(t, "transaction", NewTransaction(transaction))
+	assertNoPassword(t, "transactions", NewTransactions([]models.Transaction{transaction}))
+
+	// Models must not leak the hash either if one is ever serialized by mistake
+	assertNoPassword(t, "models.User", testUser(1))
+	assertNoPassword(t, "models.Transaction", transaction)
+}
+
+func TestAssociationsOmittedWhenNotLoaded(t *testing.T) {
+	property := NewProperty(models.Property{OwnerID: 1})
+	if property.Owner != nil || property.PropertyType != nil || property.PropertyCategory != nil {
+		t.Errorf("expected unloaded associations to be omitted, got %+v", property)
+	}
+}
@@ -0,0 +1,53 @@
+package response
+
+import (
+	"time"
+
+	"golang-test/models"
+)
+
+type Transaction struct {
+	ID         uint       `json:"id"`
+	ClientID   uint       `json:"clientId"`
+	OwnerID    uint       `json:"ownerId"`
+	PropertyID uint       `json:"propertyId"`
+	Type       string     `json:"type"`
+	Price      float32    `json:"price"`
+	Status     string     `json:"status"`
+	OfferID    *uint      `json:"offerId,omitempty"`
+	ClosedAt   *time.Time `json:"closedAt,omitempty"`
+	Client     *User      `json:"client,omitempty"`
+	Owner      *User      `json:"owner,omitempty"`
+	Property   *Property  `json:"property,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+func NewTransaction(t models.Transaction) Transaction {
+	transaction := Transaction{
+		ID:         t.ID,
+		ClientID:   t.ClientID,
+		OwnerID:    t.OwnerID,
+		PropertyID: t.PropertyID,
+		Type:       t.Type,
+		Price:      t.Price,
+		Status:     t.Status,
+		OfferID:    t.OfferID,
+		ClosedAt:   t.ClosedAt,
+		Client:     newLoadedUser(t.Client),
+		Owner:      newLoadedUser(t.Owner),
+		CreatedAt:  t.CreatedAt,
+	}
+	if t.Property.ID != 0 {
+		property := NewProperty(t.Property)
+		transaction.Property = &property
+	}
+	return transaction
+}
+
+func NewTransactions(transactions []models.Transaction) []Transaction {
+	result := make([]Transaction, 0, len(transactions))
+	for _, t := range transactions {
+		result = append(result, NewTransaction(t))
+	}
+	return result
+}
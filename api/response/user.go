@@ -0,0 +1,38 @@
+// Package response defines the JSON shapes returned by the API. Models are never
+// serialized directly, every response type lists the fields it exposes.
+package response
+
+import "golang-test/models"
+
+type User struct {
+	ID     uint   `json:"id"`
+	Name   string `json:"name"`
+	Email  string `json:"email"`
+	RoleID uint   `json:"roleId"`
+}
+
+func NewUser(u models.User) User {
+	return User{
+		ID:     u.ID,
+		Name:   u.Name,
+		Email:  u.Email,
+		RoleID: u.RoleID,
+	}
+}
+
+func NewUsers(users []models.User) []User {
+	result := make([]User, 0, len(users))
+	for _, u := range users {
+		result = append(result, NewUser(u))
+	}
+	return result
+}
+
+// newLoadedUser returns nil when the association was not preloaded
+func newLoadedUser(u models.User) *User {
+	if u.ID == 0 {
+		return nil
+	}
+	user := NewUser(u)
+	return &user
+}
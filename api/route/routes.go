@@ -2,41 +2,94 @@ package route
 
 import (
 	"golang-test/api/handler"
+	"golang-test/models"
 	"golang-test/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRouter(userHandler *handler.UserHandler, authHandler *handler.AuthHandler, roleHandler *handler.RoleHandler, propertiesHandler *handler.PropertiesHandler, categoryHandler *handler.PropertyCategoryHandler, propertyTypesHandler *handler.PropertyTypeHandler, transactionHandler *handler.TransactionHandler) *gin.Engine {
+func SetupRouter(userHandler *handler.UserHandler, authHandler *handler.AuthHandler, roleHandler *handler.RoleHandler, propertiesHandler *handler.PropertiesHandler, categoryHandler *handler.PropertyCategoryHandler, propertyTypesHandler *handler.PropertyTypeHandler, transactionHandler *handler.TransactionHandler, amenityHandler *handler.AmenityHandler, idempotencyHandler *handler.IdempotencyHandler, offerHandler *handler.OfferHandler, leaseHandler *handler.LeaseHandler, paymentHandler *handler.PaymentHandler) *gin.Engine {
 	router := gin.Default()
 
 	router.POST("/login", authHandler.Login)
+	router.POST("/auth/refresh", authHandler.Refresh)
+	router.GET("/.well-known/jwks.json", authHandler.JWKS)
 	router.POST("/users", userHandler.CreateUser)
 
 	authorizedRouter := router.Group("/")
-	authorizedRouter.Use(utils.AuthMiddleware([]uint{uint(0)}))
+	authorizedRouter.Use(utils.AuthMiddleware())
+	authorizedRouter.POST("/auth/logout", authHandler.Logout)
+	authorizedRouter.POST("/auth/logout-all", authHandler.LogoutAll)
 	authorizedRouter.GET("/users", userHandler.GetAllUsers)
 	authorizedRouter.GET("/users/:id", userHandler.GetUserById)
 	authorizedRouter.GET("/properties", propertiesHandler.GetProperties)
 	authorizedRouter.GET("/properties/:id", propertiesHandler.GetPropertyByID)
 	authorizedRouter.GET("/categories", categoryHandler.GetCategories)
 	authorizedRouter.GET("/types", propertyTypesHandler.GetTypes)
-	authorizedRouter.POST("/transactions", transactionHandler.CreateTransaction)
+	authorizedRouter.GET("/amenities", amenityHandler.GetAmenities)
+	authorizedRouter.POST("/transactions", idempotencyHandler.Idempotent(), transactionHandler.CreateTransaction)
 	authorizedRouter.GET("/transactions", transactionHandler.GetUserTransactions)
+	authorizedRouter.POST("/transactions/:id/complete", transactionHandler.CompleteTransaction)
+	authorizedRouter.POST("/transactions/:id/cancel", transactionHandler.CancelTransaction)
+	authorizedRouter.POST("/properties/:id/offers", idempotencyHandler.Idempotent(), offerHandler.CreateOffer)
+	authorizedRouter.GET("/offers", offerHandler.GetOffers)
+	authorizedRouter.GET("/offers/:id", offerHandler.GetOffer)
+	authorizedRouter.POST("/offers/:id/accept", idempotencyHandler.Idempotent(), offerHandler.AcceptOffer)
+	authorizedRouter.POST("/offers/:id/reject", offerHandler.RejectOffer)
+	authorizedRouter.POST("/offers/:id/counter", idempotencyHandler.Idempotent(), offerHandler.CounterOffer)
+	authorizedRouter.POST("/offers/:id/withdraw", offerHandler.WithdrawOffer)
+	authorizedRouter.GET("/leases", leaseHandler.GetLeases)
+	authorizedRouter.GET("/leases/:id", leaseHandler.GetLease)
+	authorizedRouter.POST("/leases/:id/renew", leaseHandler.RenewLease)
+	authorizedRouter.POST("/leases/:id/terminate", leaseHandler.TerminateLease)
+	authorizedRouter.POST("/leases/:id/payments", idempotencyHandler.Idempotent(), paymentHandler.RecordPayment)
+	authorizedRouter.GET("/leases/:id/payments", paymentHandler.GetLeasePayments)
+	authorizedRouter.GET("/leases/:id/ledger", paymentHandler.GetLedger)
+	authorizedRouter.GET("/leases/:id/balance", paymentHandler.GetLeaseBalance)
+	authorizedRouter.GET("/installments/overdue", paymentHandler.GetOverdueInstallments)
+	authorizedRouter.POST("/payments/:id/refund", idempotencyHandler.Idempotent(), paymentHandler.RefundPayment)
 
-
-	authorizedRouterAdminOrOwner := router.Group("/")
-	authorizedRouterAdminOrOwner.Use(utils.AuthMiddleware([]uint{uint(2), uint(3)}))
-	authorizedRouterAdminOrOwner.POST("/properties", propertiesHandler.CreateProperty)
-	authorizedRouterAdminOrOwner.PUT("/properties/:id", propertiesHandler.UpdateProperty)
-	authorizedRouterAdminOrOwner.DELETE("/properties/:id", propertiesHandler.DeleteProperty)
+	authorizedRouter.POST("/properties", utils.RequirePermission(models.PermissionPropertyCreate), propertiesHandler.CreateProperty)
+	authorizedRouter.PUT("/properties/:id", utils.RequirePermission(models.PermissionPropertyUpdate), propertiesHandler.UpdateProperty)
+	authorizedRouter.PATCH("/properties/:id", utils.RequirePermission(models.PermissionPropertyUpdate), propertiesHandler.PatchProperty)
+	authorizedRouter.DELETE("/properties/:id", utils.RequirePermission(models.PermissionPropertyDelete), propertiesHandler.DeleteProperty)
+	authorizedRouter.POST("/properties/:id/publish", utils.RequirePermission(models.PermissionPropertyUpdate), propertiesHandler.PublishProperty)
+	authorizedRouter.POST("/properties/:id/withdraw", utils.RequirePermission(models.PermissionPropertyUpdate), propertiesHandler.WithdrawProperty)
+	authorizedRouter.POST("/properties/:id/relist", utils.RequirePermission(models.PermissionPropertyUpdate), propertiesHandler.RelistProperty)
+	authorizedRouter.GET("/properties/:id/status-history", propertiesHandler.GetPropertyStatusHistory)
+	authorizedRouter.GET("/properties/:id/images", propertiesHandler.GetPropertyImages)
+	authorizedRouter.POST("/properties/:id/images", utils.RequirePermission(models.PermissionPropertyUpdate), propertiesHandler.AddPropertyImages)
+	authorizedRouter.POST("/properties/:id/images/uploads", utils.RequirePermission(models.PermissionPropertyUpdate), propertiesHandler.RequestPropertyImageUploads)
+	authorizedRouter.POST("/properties/:id/images/uploads/confirm", utils.RequirePermission(models.PermissionPropertyUpdate), propertiesHandler.ConfirmPropertyImageUploads)
+	authorizedRouter.PUT("/properties/:id/images/order", utils.RequirePermission(models.PermissionPropertyUpdate), propertiesHandler.ReorderPropertyImages)
+	authorizedRouter.PUT("/properties/:id/images/:imageId/cover", utils.RequirePermission(models.PermissionPropertyUpdate), propertiesHandler.SetPropertyCoverImage)
+	authorizedRouter.DELETE("/properties/:id/images/:imageId", utils.RequirePermission(models.PermissionPropertyUpdate), propertiesHandler.DeletePropertyImage)
 
 
 
 	adminAuthRoute := router.Group("/admin")
-	adminAuthRoute.Use(utils.AuthMiddleware([]uint{uint(3)}))
-	adminAuthRoute.POST("/categories", categoryHandler.CreateCategory)
-	adminAuthRoute.POST("/types", propertyTypesHandler.CreateType)
-	adminAuthRoute.POST("/roles", roleHandler.CreateRole)
+	adminAuthRoute.Use(utils.AuthMiddleware())
+	adminAuthRoute.POST("/categories", utils.RequirePermission(models.PermissionCategoryManage), categoryHandler.CreateCategory)
+	adminAuthRoute.POST("/types", utils.RequirePermission(models.PermissionTypeManage), propertyTypesHandler.CreateType)
+	adminAuthRoute.POST("/properties/:id/review", utils.RequirePermission(models.PermissionPropertyReview), propertiesHandler.ReviewProperty)
+	adminAuthRoute.POST("/properties/:id/withdraw", utils.RequirePermission(models.PermissionPropertyReview), propertiesHandler.WithdrawProperty)
+
+	amenityRoute := adminAuthRoute.Group("/")
+	amenityRoute.Use(utils.RequirePermission(models.PermissionAmenityManage))
+	amenityRoute.GET("/amenities", amenityHandler.GetAmenities)
+	amenityRoute.POST("/amenities", amenityHandler.CreateAmenity)
+	amenityRoute.PUT("/amenities/:id", amenityHandler.UpdateAmenity)
+	amenityRoute.DELETE("/amenities/:id", amenityHandler.DeleteAmenity)
+
+	roleRoute := adminAuthRoute.Group("/")
+	roleRoute.Use(utils.RequirePermission(models.PermissionRoleManage))
+	roleRoute.POST("/roles", roleHandler.CreateRole)
+	roleRoute.GET("/roles", roleHandler.GetRoles)
+	roleRoute.GET("/roles/:id", roleHandler.GetRoleByID)
+	roleRoute.PUT("/roles/:id/permissions", roleHandler.SetRolePermissions)
+	roleRoute.POST("/roles/:id/permissions", roleHandler.AddRolePermissions)
+	roleRoute.DELETE("/roles/:id/permissions/:permission", roleHandler.RemoveRolePermission)
+	roleRoute.GET("/permissions", roleHandler.GetPermissions)
+	roleRoute.PUT("/users/:id/role", userHandler.SetUserRole)
 	return router
 }
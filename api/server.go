@@ -2,13 +2,21 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"regexp"
 
 	"golang-test/api/handler"
 	"golang-test/api/route"
+	"golang-test/cache"
 	"golang-test/config"
+	"golang-test/jobs"
 	"golang-test/models"
+	"golang-test/payment"
+	"golang-test/queue"
+	"golang-test/storage"
+	"golang-test/utils"
 
 	"github.com/go-redis/redis/v8"
 	"gorm.io/driver/postgres"
@@ -19,54 +27,60 @@ func StartServer() {
 	// Load configuration
 	cfg := config.AppConfig
 
-	dbURL := fmt.Sprintf("postgresql://%s:%s@%s:%s/%s", cfg.DBUser, cfg.DBPassword, cfg.DBHost, cfg.DBPort, cfg.DBName)
+	db := connectDatabase(cfg)
 
-	db, err := gorm.Open(postgres.Open(dbURL), &gorm.Config{})
-	if err != nil {
-		log.Fatalf("Error connecting to database: %v", err)
+	// Load the JWT signing and verification keys
+	if err := utils.LoadJWTKeys(cfg); err != nil {
+		log.Fatalf("Error loading JWT keys: %v", err)
 	}
 
 	// Run database migrations
 	// Migrate function will apply the migration
-	err = func() error {
-		return db.AutoMigrate(&models.User{}, &models.Role{}, &models.Property{}, &models.PropertyCategory{}, &models.PropertyType{}, &models.Transaction{})
+	err := func() error {
+		return db.AutoMigrate(&models.User{}, &models.Role{}, &models.Permission{}, &models.Property{}, &models.PropertyCategory{}, &models.PropertyType{}, &models.Transaction{}, &models.Amenity{}, &models.PropertyImage{}, &models.RefreshToken{}, &models.RevokedToken{}, &models.PropertyStatusChange{}, &models.IdempotencyKey{}, &models.Offer{}, &models.Lease{}, &models.RentInstallment{}, &models.Payment{}, &models.LedgerJournal{}, &models.LedgerEntry{})
 	}()
 	if err != nil {
 		log.Fatal("Failed to run migrations:", err)
 	}
-	// Redis configuration setup function (to be called during application startup)
-	redisClient := func () *redis.Client {
-		// Read configuration from environment or config file
-		redisAddr := config.AppConfig.RedisAddr
-		redisPassword := config.AppConfig.RedisPass
-		redisDB := config.AppConfig.RedisDB
+	if err := backfillListingModes(db); err != nil {
+		log.Fatal("Failed to set listing modes:", err)
+	}
+	if err := seedPermissions(db); err != nil {
+		log.Fatal("Failed to seed permissions:", err)
+	}
+	if err := setupPropertySearch(db, cfg.SearchLanguage); err != nil {
+		log.Fatal("Failed to set up property search:", err)
+	}
+	redisClient := connectRedis()
 
-		// Set defaults if not provided
-		if redisAddr == "" {
-			redisAddr = "localhost:6379"
-		}
-		if redisDB == 0 {
-			redisDB = 0 // Default Redis DB
-		}
+	// One storage driver is shared by the whole process
+	store, err := storage.New(cfg)
+	if err != nil {
+		log.Fatalf("Error setting up storage: %v", err)
+	}
 
-		// Create Redis client
-		client := redis.NewClient(&redis.Options{
-			Addr:     redisAddr,
-			Password: redisPassword,
-			DB:       redisDB,
-		})
-
-		// Ping Redis to check connection
-		_, err := client.Ping(context.Background()).Result()
-		if err != nil {
-			log.Printf("Warning: Failed to connect to Redis: %v", err)
-			// Return nil to indicate Redis is not available
-			return nil
-		}
+	// The cache gets its own client, it keeps retrying while Redis is down
+	responseCache, err := cache.New(cfg, newRedisClient())
+	if err != nil {
+		log.Fatalf("Error setting up cache: %v", err)
+	}
 
-		log.Println("Connected to Redis successfully")
-		return client
-	}()
+	// Payments are charged in the API and settled by the worker, both use the same driver
+	payments, err := payment.New(cfg)
+	if err != nil {
+		log.Fatalf("Error setting up payments: %v", err)
+	}
+
+	// Jobs go to redis for the workers started with "main worker". With QUEUE_DRIVER=memory
+	// or while redis is down at startup they are handled by a worker inside this process
+	// and are lost when it stops.
+	jobQueue, err := queue.New(cfg, redisClient)
+	if err != nil {
+		log.Fatalf("Error setting up job queue: %v", err)
+	}
+	if _, ok := jobQueue.(*queue.Memory); ok {
+		go newWorker(cfg, jobQueue, db, store, responseCache, payments).Run(context.Background())
+	}
 
 	// Initialize handlers
 	userHandler := &handler.UserHandler{DB: db}
@@ -74,14 +88,26 @@ func StartServer() {
 	roleHandler := &handler.RoleHandler{DB: db}
 	propertiesHandler := &handler.PropertiesHandler{
 		DB: db,
-		Redis: redisClient,
+		Cache: responseCache,
+		Storage: store,
+		Jobs: jobQueue,
 	}
 	categoryHandler := &handler.PropertyCategoryHandler{DB: db}
 	propertyTypesHandler := &handler.PropertyTypeHandler{DB: db}
-	transactionHandler := &handler.TransactionHandler{DB: db}
+	transactionHandler := &handler.TransactionHandler{DB: db, Cache: responseCache, Jobs: jobQueue}
+	amenityHandler := &handler.AmenityHandler{DB: db, Cache: responseCache}
+	idempotencyHandler := &handler.IdempotencyHandler{DB: db, ReservationTimeout: cfg.IdempotencyReservationTimeout}
+	offerHandler := &handler.OfferHandler{DB: db, Cache: responseCache, Jobs: jobQueue}
+	leaseHandler := &handler.LeaseHandler{DB: db, Cache: responseCache, Jobs: jobQueue}
+	paymentHandler := &handler.PaymentHandler{DB: db, Jobs: jobQueue, Payments: payments, Currency: cfg.PaymentCurrency}
+
+	// Check revoked access tokens against the denylist
+	utils.IsTokenRevoked = authHandler.IsTokenRevoked
+	// Resolve permissions through the user's current role
+	utils.UserHasPermission = roleHandler.UserHasPermission
 
 	// Set up routes
-	r := route.SetupRouter(userHandler, authHandler, roleHandler, propertiesHandler, categoryHandler, propertyTypesHandler, transactionHandler)
+	r := route.SetupRouter(userHandler, authHandler, roleHandler, propertiesHandler, categoryHandler, propertyTypesHandler, transactionHandler, amenityHandler, idempotencyHandler, offerHandler, leaseHandler, paymentHandler)
 
 	// Start the server
 	log.Println("Starting server on port 8085...")
@@ -89,3 +115,181 @@ func StartServer() {
 		log.Fatalf("Error starting server: %v", err)
 	}
 }
+
+// connectDatabase opens the postgres connection, exiting if it fails
+func connectDatabase(cfg config.Config) *gorm.DB {
+	dbURL := fmt.Sprintf("postgresql://%s:%s@%s:%s/%s", cfg.DBUser, cfg.DBPassword, cfg.DBHost, cfg.DBPort, cfg.DBName)
+
+	db, err := gorm.Open(postgres.Open(dbURL), &gorm.Config{})
+	if err != nil {
+		log.Fatalf("Error connecting to database: %v", err)
+	}
+	return db
+}
+
+// connectRedis is the Redis configuration setup function, it returns nil when Redis is
+// not available
+func connectRedis() *redis.Client {
+	client := newRedisClient()
+
+	// Ping Redis to check connection
+	_, err := client.Ping(context.Background()).Result()
+	if err != nil {
+		log.Printf("Warning: Failed to connect to Redis: %v", err)
+		// Return nil to indicate Redis is not available
+		return nil
+	}
+
+	log.Println("Connected to Redis successfully")
+	return client
+}
+
+// newRedisClient creates a client from the configuration without connecting
+func newRedisClient() *redis.Client {
+	// Read configuration from environment or config file
+	redisAddr := config.AppConfig.RedisAddr
+	redisPassword := config.AppConfig.RedisPass
+	redisDB := config.AppConfig.RedisDB
+
+	// Set defaults if not provided
+	if redisAddr == "" {
+		redisAddr = "localhost:6379"
+	}
+	if redisDB == 0 {
+		redisDB = 0 // Default Redis DB
+	}
+
+	// Create Redis client
+	return redis.NewClient(&redis.Options{
+		Addr:     redisAddr,
+		Password: redisPassword,
+		DB:       redisDB,
+	})
+}
+
+// newWorker creates a worker that handles every job type
+func newWorker(cfg config.Config, jobQueue queue.Queue, db *gorm.DB, store storage.Storage, responseCache cache.Cache, payments payment.PaymentProvider) *queue.Worker {
+	worker := queue.NewWorker(jobQueue, cfg.WorkerConcurrency)
+	jobs.Register(worker, jobs.Deps{
+		DB:                db,
+		Storage:           store,
+		Payments:          payments,
+		PropertiesChanged: handler.PropertyCacheInvalidator(responseCache),
+	})
+	return worker
+}
+
+// seedPermissions makes sure every known permission exists. Roles named "owner" and
+// "admin" that have no permissions yet get the grants they used to have through
+// hard-coded role ids, after that they are managed through the admin endpoints.
+// Permissions introduced by a new release are granted to the admin role. The role new
+// users sign up with is created without permissions if it's missing.
+func seedPermissions(db *gorm.DB) error {
+	if err := db.Where(models.Role{Name: models.DefaultRoleName}).FirstOrCreate(&models.Role{}).Error; err != nil {
+		return err
+	}
+
+	var all, created []models.Permission
+	for name, description := range models.DefaultPermissions {
+		permission := models.Permission{Name: name}
+		result := db.Where(models.Permission{Name: name}).Attrs(models.Permission{Description: description}).FirstOrCreate(&permission)
+		if result.Error != nil {
+			return result.Error
+		}
+		all = append(all, permission)
+		if result.RowsAffected > 0 {
+			created = append(created, permission)
+		}
+	}
+
+	defaults := map[string][]string{
+		"admin": nil,
+		"owner": {models.PermissionPropertyCreate, models.PermissionPropertyUpdate, models.PermissionPropertyDelete},
+	}
+	for roleName, names := range defaults {
+		var role models.Role
+		if err := db.Where("name = ?", roleName).First(&role).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue
+			}
+			return err
+		}
+
+		var grants []models.Permission
+		if db.Model(&role).Association("Permissions").Count() > 0 {
+			if names != nil || len(created) == 0 {
+				continue
+			}
+			grants = created
+		} else if names == nil {
+			grants = all
+		} else if err := db.Where("name IN ?", names).Find(&grants).Error; err != nil {
+			return err
+		}
+		if err := db.Model(&role).Association("Permissions").Append(grants); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillListingModes sets the listing mode of properties created before it existed.
+// Those were offered for rent if their type was named "rent", otherwise for sale.
+func backfillListingModes(db *gorm.DB) error {
+	return db.Exec(`UPDATE properties SET listing_mode = CASE
+		WHEN property_type_id IN (SELECT id FROM property_types WHERE name = 'rent') THEN ?
+		ELSE ? END
+		WHERE listing_mode IS NULL OR listing_mode = ''`, models.ListingModeRent, models.ListingModeSale).Error
+}
+
+var searchLanguagePattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// setupPropertySearch keeps properties.search_vector up to date through a trigger that
+// uses the configured text search language. The language is stored as the trigger
+// function's comment so existing rows are re-indexed when it changes.
+func setupPropertySearch(db *gorm.DB, language string) error {
+	var count int64
+	if err := db.Raw("SELECT count(*) FROM pg_ts_config WHERE cfgname = ?", language).Scan(&count).Error; err != nil {
+		return err
+	}
+	// The name ends up in the function body, so only plain identifiers are accepted
+	if count == 0 || !searchLanguagePattern.MatchString(language) {
+		return fmt.Errorf("unknown text search language %q", language)
+	}
+
+	vector := func(prefix string) string {
+		return fmt.Sprintf(
+			"setweight(to_tsvector('%[1]s', coalesce(%[2]sname, '')), 'A') || "+
+				"setweight(to_tsvector('%[1]s', coalesce(%[2]slocation, '')), 'B') || "+
+				"setweight(to_tsvector('%[1]s', coalesce(%[2]sdescription, '')), 'C')",
+			language, prefix)
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var current []string
+		if err := tx.Raw("SELECT coalesce(obj_description(oid, 'pg_proc'), '') FROM pg_proc WHERE proname = 'properties_search_vector_update'").Scan(&current).Error; err != nil {
+			return err
+		}
+
+		statements := []string{
+			`CREATE OR REPLACE FUNCTION properties_search_vector_update() RETURNS trigger AS $$
+BEGIN
+	NEW.search_vector := ` + vector("NEW.") + `;
+	RETURN NEW;
+END
+$$ LANGUAGE plpgsql`,
+			fmt.Sprintf("COMMENT ON FUNCTION properties_search_vector_update() IS '%s'", language),
+			"DROP TRIGGER IF EXISTS properties_search_vector_update ON properties",
+			"CREATE TRIGGER properties_search_vector_update BEFORE INSERT OR UPDATE ON properties FOR EACH ROW EXECUTE FUNCTION properties_search_vector_update()",
+		}
+		if len(current) == 0 || current[0] != language {
+			statements = append(statements, "UPDATE properties SET search_vector = "+vector(""))
+		}
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
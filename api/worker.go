@@ -0,0 +1,51 @@
+package api
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"golang-test/cache"
+	"golang-test/config"
+	"golang-test/payment"
+	"golang-test/queue"
+	"golang-test/storage"
+)
+
+// StartWorker runs background jobs until the process is interrupted, the jobs that are
+// running are finished before it exits
+func StartWorker() {
+	cfg := config.AppConfig
+	if cfg.QueueDriver == "memory" {
+		log.Fatal("The memory queue driver only works inside the API process")
+	}
+
+	db := connectDatabase(cfg)
+	// The client reconnects on its own, jobs are picked up again once redis is back
+	jobQueue, err := queue.New(cfg, newRedisClient())
+	if err != nil {
+		log.Fatalf("Error setting up job queue: %v", err)
+	}
+	store, err := storage.New(cfg)
+	if err != nil {
+		log.Fatalf("Error setting up storage: %v", err)
+	}
+	// Jobs that change properties drop their cached listings
+	responseCache, err := cache.New(cfg, newRedisClient())
+	if err != nil {
+		log.Fatalf("Error setting up cache: %v", err)
+	}
+	payments, err := payment.New(cfg)
+	if err != nil {
+		log.Fatalf("Error setting up payments: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("Starting worker with %d goroutines...", cfg.WorkerConcurrency)
+	newWorker(cfg, jobQueue, db, store, responseCache, payments).Run(ctx)
+	log.Println("Worker stopped")
+}
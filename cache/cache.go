@@ -0,0 +1,41 @@
+// Package cache stores serialized responses in Redis or, when Redis is not available, in
+// process memory.
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang-test/config"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrMiss is returned by Get when the key is not cached
+var ErrMiss = errors.New("cache miss")
+
+// Cache is a key value store whose entries expire
+type Cache interface {
+	// Get returns the cached value or ErrMiss
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set caches the value for ttl
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete removes the keys
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// New creates the cache selected by CACHE_DRIVER: "redis" (the default), which falls
+// back to an in-process LRU of CACHE_SIZE entries while Redis is unreachable, or
+// "memory" for the LRU alone
+func New(cfg config.Config, client *redis.Client) (Cache, error) {
+	switch cfg.CacheDriver {
+	case "", "redis":
+		return NewFallback(NewRedis(client, "cache"), NewLRU(cfg.CacheSize), 5*time.Second), nil
+	case "memory":
+		return NewLRU(cfg.CacheSize), nil
+	default:
+		return nil, fmt.Errorf("unknown cache driver %q", cfg.CacheDriver)
+	}
+}
@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	lru := NewLRU(2)
+	lru.Set(ctx, "a", []byte("1"), time.Minute)
+	lru.Set(ctx, "b", []byte("2"), time.Minute)
+	lru.Get(ctx, "a")
+	lru.Set(ctx, "c", []byte("3"), time.Minute)
+
+	if _, err := lru.Get(ctx, "b"); !errors.Is(err, ErrMiss) {
+		t.Fatalf("b should have been evicted, got %v", err)
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, err := lru.Get(ctx, key); err != nil {
+			t.Fatalf("%s: %v", key, err)
+		}
+	}
+}
+
+func TestLRUExpiresEntries(t *testing.T) {
+	ctx := context.Background()
+	lru := NewLRU(10)
+	lru.Set(ctx, "a", []byte("1"), time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	if _, err := lru.Get(ctx, "a"); !errors.Is(err, ErrMiss) {
+		t.Fatalf("a should have expired, got %v", err)
+	}
+}
+
+func TestTaggedInvalidate(t *testing.T) {
+	ctx := context.Background()
+	tagged := Tagged{Cache: NewLRU(10)}
+	first, err := tagged.Key(ctx, "properties:all", "properties", "category:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := tagged.Key(ctx, "properties:all", "properties", "category:2")
+	if again, _ := tagged.Key(ctx, "properties:all", "properties", "category:1"); again != first {
+		t.Fatalf("key changed without invalidation: %s != %s", again, first)
+	}
+
+	if err := tagged.Invalidate(ctx, "category:1"); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := tagged.Key(ctx, "properties:all", "properties", "category:1"); again == first {
+		t.Fatal("key didn't change after invalidating its tag")
+	}
+	if again, _ := tagged.Key(ctx, "properties:all", "properties", "category:2"); again != other {
+		t.Fatal("invalidating a tag changed a key without it")
+	}
+}
+
+func TestFallbackUsesMemoryWhenRedisIsDown(t *testing.T) {
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer client.Close()
+	fallback := NewFallback(NewRedis(client, "cache"), NewLRU(10), time.Hour)
+	defer fallback.Close()
+
+	if err := fallback.Set(ctx, "a", []byte("1"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	value, err := fallback.Get(ctx, "a")
+	if err != nil || string(value) != "1" {
+		t.Fatalf("got %q, %v", value, err)
+	}
+	if err := fallback.Delete(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fallback.Get(ctx, "a"); !errors.Is(err, ErrMiss) {
+		t.Fatalf("a should have been deleted, got %v", err)
+	}
+}
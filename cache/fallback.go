@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// Fallback serves from Redis while it is reachable and from the in-process cache
+// otherwise. While Redis is down it is pinged every interval and used again once it
+// answers. Deletes always reach the in-process cache, so it is never stale when Redis
+// goes down again.
+type Fallback struct {
+	primary   *Redis
+	secondary Cache
+	healthy   atomic.Bool
+	stop      chan struct{}
+}
+
+// NewFallback checks Redis right away and starts the reconnect loop
+func NewFallback(primary *Redis, secondary Cache, interval time.Duration) *Fallback {
+	f := &Fallback{primary: primary, secondary: secondary, stop: make(chan struct{})}
+	ctx, cancel := context.WithTimeout(context.Background(), interval)
+	defer cancel()
+	if err := primary.Ping(ctx); err != nil {
+		log.Printf("Warning: Redis cache unavailable, using in-process cache: %v", err)
+	} else {
+		f.healthy.Store(true)
+	}
+	go f.reconnect(interval)
+	return f
+}
+
+// Close stops the reconnect loop
+func (f *Fallback) Close() {
+	close(f.stop)
+}
+
+func (f *Fallback) Get(ctx context.Context, key string) ([]byte, error) {
+	if f.healthy.Load() {
+		value, err := f.primary.Get(ctx, key)
+		if err == nil || errors.Is(err, ErrMiss) || !f.fail(err) {
+			return value, err
+		}
+	}
+	return f.secondary.Get(ctx, key)
+}
+
+func (f *Fallback) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if f.healthy.Load() {
+		err := f.primary.Set(ctx, key, value, ttl)
+		if err == nil || !f.fail(err) {
+			return err
+		}
+	}
+	return f.secondary.Set(ctx, key, value, ttl)
+}
+
+func (f *Fallback) Delete(ctx context.Context, keys ...string) error {
+	if err := f.secondary.Delete(ctx, keys...); err != nil {
+		return err
+	}
+	// While Redis is down it is cleared before it's used again, so nothing is missed
+	if f.healthy.Load() {
+		if err := f.primary.Delete(ctx, keys...); err != nil && !f.fail(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// fail switches to the in-process cache, errors caused by the caller's context don't
+// say anything about Redis and are returned as they are
+func (f *Fallback) fail(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if f.healthy.CompareAndSwap(true, false) {
+		log.Printf("Warning: Redis cache unavailable, using in-process cache: %v", err)
+	}
+	return true
+}
+
+func (f *Fallback) reconnect(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-ticker.C:
+		}
+		if f.healthy.Load() {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		// Entries written before the outage may have been invalidated since
+		err := f.primary.Ping(ctx)
+		if err == nil {
+			err = f.primary.Clear(ctx)
+		}
+		cancel()
+		if err == nil {
+			f.healthy.Store(true)
+			log.Println("Redis cache is available again")
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tagVersionTTL keeps tag versions around well beyond the entries built with them
+const tagVersionTTL = 7 * 24 * time.Hour
+
+// Tagged builds keys that include the current version of each of their tags. Invalidating
+// a tag gives it a new version, which makes every entry built with the old one
+// unreachable without looking for those keys; the entries then expire on their own.
+type Tagged struct {
+	Cache Cache
+}
+
+// Key returns key suffixed with the versions of the tags
+func (t Tagged) Key(ctx context.Context, key string, tags ...string) (string, error) {
+	versions := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		version, err := t.Cache.Get(ctx, "tag:"+tag)
+		if errors.Is(err, ErrMiss) {
+			// A new version can't match any entry, so racing writers don't matter
+			version = []byte(strconv.FormatInt(time.Now().UnixNano(), 36))
+			err = t.Cache.Set(ctx, "tag:"+tag, version, tagVersionTTL)
+		}
+		if err != nil {
+			return "", err
+		}
+		versions = append(versions, tag+"@"+string(version))
+	}
+	return key + "|" + strings.Join(versions, "|"), nil
+}
+
+// Invalidate drops the versions of the tags. They are deleted rather than replaced so
+// a fallback cache holding old versions drops them as well.
+func (t Tagged) Invalidate(ctx context.Context, tags ...string) error {
+	keys := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		keys = append(keys, "tag:"+tag)
+	}
+	return t.Cache.Delete(ctx, keys...)
+}
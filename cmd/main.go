@@ -1,11 +1,18 @@
 package main
 
 import (
+	"os"
+
 	"golang-test/api"
 	"golang-test/config"
 )
 
 func main() {
 	config.LoadConfig()
+	// "main worker" runs the background jobs instead of the API
+	if len(os.Args) > 1 && os.Args[1] == "worker" {
+		api.StartWorker()
+		return
+	}
 	api.StartServer()
 }
@@ -6,6 +6,7 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 
@@ -22,6 +23,26 @@ type Config struct {
 	RedisAddr string `mapstructure:"REDIS_ADDR"`
 	RedisPass string `mapstructure:"REDIS_PASS"`
 	RedisDB int `mapstructure:"REDIS_DB"`
+	JWTSecret string `mapstructure:"JWT_SECRET"`
+	JWTSigningKey string `mapstructure:"JWT_SIGNING_KEY"`
+	JWTKeyID string `mapstructure:"JWT_KEY_ID"`
+	JWTVerificationKeys string `mapstructure:"JWT_VERIFICATION_KEYS"`
+	SearchLanguage string `mapstructure:"SEARCH_LANGUAGE"`
+	StorageDriver string `mapstructure:"STORAGE_DRIVER"`
+	StorageLocalPath string `mapstructure:"STORAGE_LOCAL_PATH"`
+	S3Endpoint string `mapstructure:"S3_ENDPOINT"`
+	S3Region string `mapstructure:"S3_REGION"`
+	S3AccessKeyID string `mapstructure:"S3_ACCESS_KEY_ID"`
+	S3SecretAccessKey string `mapstructure:"S3_SECRET_ACCESS_KEY"`
+	S3UsePathStyle bool `mapstructure:"S3_USE_PATH_STYLE"`
+	StorageRetryAttempts int `mapstructure:"STORAGE_RETRY_ATTEMPTS"`
+	QueueDriver string `mapstructure:"QUEUE_DRIVER"`
+	WorkerConcurrency int `mapstructure:"WORKER_CONCURRENCY"`
+	CacheDriver string `mapstructure:"CACHE_DRIVER"`
+	CacheSize int `mapstructure:"CACHE_SIZE"`
+	PaymentDriver string `mapstructure:"PAYMENT_DRIVER"`
+	PaymentCurrency string `mapstructure:"PAYMENT_CURRENCY"`
+	IdempotencyReservationTimeout time.Duration `mapstructure:"IDEMPOTENCY_RESERVATION_TIMEOUT"`
 }
 
 var AppConfig Config
@@ -30,6 +51,12 @@ func LoadConfig() {
 
 	viper.SetConfigFile(".env")
 	viper.AutomaticEnv()
+	viper.SetDefault("SEARCH_LANGUAGE", "english")
+	viper.SetDefault("STORAGE_RETRY_ATTEMPTS", 3)
+	viper.SetDefault("WORKER_CONCURRENCY", 4)
+	viper.SetDefault("CACHE_SIZE", 1000)
+	viper.SetDefault("PAYMENT_CURRENCY", "EUR")
+	viper.SetDefault("IDEMPOTENCY_RESERVATION_TIMEOUT", 5*time.Minute)
 
 	err := viper.ReadInConfig()
 	if err != nil {
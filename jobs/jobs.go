@@ -0,0 +1,189 @@
+// Package jobs defines the background jobs and their handlers. The API enqueues them
+// and the worker started with "main worker" runs them.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang-test/models"
+	"golang-test/payment"
+	"golang-test/queue"
+	"golang-test/storage"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// TypeDeleteFiles removes files from storage
+	TypeDeleteFiles = "storage.delete_files"
+	// TypeExpireOffer marks an offer that wasn't answered in time as expired
+	TypeExpireOffer = "offers.expire"
+	// TypeEndLease ends a lease on its end date and puts the property back on the market
+	TypeEndLease = "leases.end"
+	// TypeChargeRent charges a rent installment to the tenant on its due date
+	TypeChargeRent = "leases.charge_rent"
+	// TypeSettlePayment checks with the payment provider whether a pending payment settled
+	TypeSettlePayment = "payments.settle"
+)
+
+// sweepInterval is how often the worker looks for due work whose job was lost, e.g.
+// because enqueueing it failed. A sweep handles at most sweepBatchSize items.
+const (
+	sweepInterval  = time.Minute
+	sweepBatchSize = 100
+)
+
+// DeleteFiles is the payload of TypeDeleteFiles
+type DeleteFiles struct {
+	Keys []string `json:"keys"`
+}
+
+// ExpireOffer is the payload of TypeExpireOffer
+type ExpireOffer struct {
+	OfferID uint `json:"offerId"`
+}
+
+// EndLease is the payload of TypeEndLease
+type EndLease struct {
+	LeaseID uint `json:"leaseId"`
+}
+
+// ChargeRent is the payload of TypeChargeRent
+type ChargeRent struct {
+	InstallmentID uint `json:"installmentId"`
+}
+
+// SettlePayment is the payload of TypeSettlePayment
+type SettlePayment struct {
+	PaymentID uint `json:"paymentId"`
+}
+
+// Deps are the services the job handlers use
+type Deps struct {
+	DB       *gorm.DB
+	Storage  storage.Storage
+	Payments payment.PaymentProvider
+	// PropertiesChanged is called with the properties a job changed, e.g. to drop their
+	// cached listings
+	PropertiesChanged func(properties ...models.Property)
+}
+
+// Register adds the handler of every job type to the worker
+func Register(worker *queue.Worker, deps Deps) {
+	queue.Handle(worker, TypeDeleteFiles, deps.deleteFiles)
+	queue.Handle(worker, TypeExpireOffer, deps.expireOffer)
+	queue.Handle(worker, TypeEndLease, deps.endLease)
+	queue.Handle(worker, TypeChargeRent, deps.chargeRent)
+	queue.Handle(worker, TypeSettlePayment, deps.settlePayment)
+	worker.Every(sweepInterval, "expire offers", deps.expireDueOffers)
+	worker.Every(sweepInterval, "end due leases", deps.endDueLeases)
+	worker.Every(sweepInterval, "charge due rent", deps.chargeDueRent)
+	worker.Every(sweepInterval, "settle pending payments", deps.settlePendingPayments)
+}
+
+// deleteFiles fails if any file could not be deleted, deleting is idempotent so the
+// retry can start over with every key
+func (d Deps) deleteFiles(ctx context.Context, payload DeleteFiles) error {
+	var errs []error
+	for _, key := range payload.Keys {
+		if err := d.Storage.Delete(ctx, key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// expireOffer only touches the offer if it is still pending and its time is up, so it
+// does nothing when the offer was answered meanwhile
+func (d Deps) expireOffer(ctx context.Context, payload ExpireOffer) error {
+	return d.DB.WithContext(ctx).Model(&models.Offer{}).
+		Where("id = ? AND status = ? AND expires_at <= ?", payload.OfferID, models.OfferStatusPending, time.Now()).
+		Update("status", models.OfferStatusExpired).Error
+}
+
+// expireDueOffers expires every pending offer whose time is up, so offers whose expiry
+// job was lost don't stay pending
+func (d Deps) expireDueOffers(ctx context.Context) error {
+	return d.DB.WithContext(ctx).Model(&models.Offer{}).
+		Where("status = ? AND expires_at <= ?", models.OfferStatusPending, time.Now()).
+		Update("status", models.OfferStatusExpired).Error
+}
+
+// endLease does nothing if the lease ended already or was renewed since the job was
+// scheduled, a renewal schedules another job for the new end date
+func (d Deps) endLease(ctx context.Context, payload EndLease) error {
+	var property models.Property
+	var ended bool
+	err := d.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		property, ended, err = EndDueLease(tx, payload.LeaseID, time.Now())
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if ended && d.PropertiesChanged != nil {
+		d.PropertiesChanged(property)
+	}
+	return nil
+}
+
+// endDueLeases ends every active lease whose end date has passed, so a lease doesn't
+// depend on its end job alone
+func (d Deps) endDueLeases(ctx context.Context) error {
+	var ids []uint
+	err := d.DB.WithContext(ctx).Model(&models.Lease{}).
+		Where("status = ? AND end_date <= ?", models.LeaseStatusActive, time.Now()).
+		Order("end_date").Limit(sweepBatchSize).Pluck("id", &ids).Error
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, id := range ids {
+		if err := d.endLease(ctx, EndLease{LeaseID: id}); err != nil {
+			errs = append(errs, fmt.Errorf("lease %d: %w", id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// EndDueLease ends the lease if it is active and its end date has passed, and makes the
+// rented property available again. It returns the property as it was before and whether
+// the lease was ended.
+func EndDueLease(tx *gorm.DB, leaseID uint, now time.Time) (models.Property, bool, error) {
+	var lease models.Lease
+	var property models.Property
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&lease, leaseID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return property, false, nil
+		}
+		return property, false, err
+	}
+	if lease.Status != models.LeaseStatusActive || lease.EndDate.After(now) {
+		return property, false, nil
+	}
+
+	status := models.LeaseStatusEnded
+	if lease.TerminatedAt != nil {
+		status = models.LeaseStatusTerminated
+	}
+	if err := tx.Model(&lease).Update("status", status).Error; err != nil {
+		return property, false, err
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&property, lease.PropertyID).Error; err != nil {
+		return property, false, err
+	}
+	// The owner may have withdrawn the property meanwhile, then it stays withdrawn
+	if property.Status == models.PropertyStatusRented {
+		updated := property
+		note := fmt.Sprintf("lease %d %s", lease.ID, status)
+		if err := models.ChangePropertyStatus(tx, &updated, models.PropertyStatusAvailable, []models.PropertyActor{models.PropertyActorSystem}, nil, note); err != nil {
+			return property, false, err
+		}
+	}
+	return property, true, nil
+}
@@ -0,0 +1,168 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang-test/models"
+	"golang-test/payment"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// errPaymentPending makes the worker check a pending payment again after a backoff
+var errPaymentPending = errors.New("payment is still pending")
+
+const (
+	// maxSettleWait is how long a payment may stay pending before it is given up as failed
+	maxSettleWait = 7 * 24 * time.Hour
+	// settleSweepDelay leaves a new payment to its own settle job before the sweep checks it
+	settleSweepDelay = 5 * time.Minute
+)
+
+// chargeRent does nothing if the installment was charged or cancelled meanwhile
+func (d Deps) chargeRent(ctx context.Context, payload ChargeRent) error {
+	return d.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return ChargeDueInstallment(tx, payload.InstallmentID, time.Now())
+	})
+}
+
+// chargeDueRent charges every scheduled installment that is due, so rent doesn't depend
+// on its charge job alone
+func (d Deps) chargeDueRent(ctx context.Context) error {
+	var ids []uint
+	err := d.DB.WithContext(ctx).Model(&models.RentInstallment{}).
+		Where("status = ? AND due_date <= ?", models.RentInstallmentStatusScheduled, time.Now()).
+		Order("due_date").Limit(sweepBatchSize).Pluck("id", &ids).Error
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, id := range ids {
+		if err := d.chargeRent(ctx, ChargeRent{InstallmentID: id}); err != nil {
+			errs = append(errs, fmt.Errorf("installment %d: %w", id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// settlePendingPayments checks every payment that has been pending for a while with the
+// provider, a payment whose settle job was lost or buried is still settled or given up
+func (d Deps) settlePendingPayments(ctx context.Context) error {
+	var ids []uint
+	err := d.DB.WithContext(ctx).Model(&models.Payment{}).
+		Where("status = ? AND created_at <= ?", models.PaymentStatusPending, time.Now().Add(-settleSweepDelay)).
+		Order("created_at").Limit(sweepBatchSize).Pluck("id", &ids).Error
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, id := range ids {
+		if err := d.settlePayment(ctx, SettlePayment{PaymentID: id}); err != nil && !errors.Is(err, errPaymentPending) {
+			errs = append(errs, fmt.Errorf("payment %d: %w", id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// settlePayment fails while the provider reports the payment as pending, so the worker
+// retries it with a growing delay. After maxSettleWait the payment is marked as failed.
+func (d Deps) settlePayment(ctx context.Context, payload SettlePayment) error {
+	var p models.Payment
+	if err := d.DB.WithContext(ctx).First(&p, payload.PaymentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if p.Status != models.PaymentStatusPending {
+		return nil
+	}
+	// The API stopped before it recorded the provider's answer. Without a reference the
+	// payment can't be looked up, so it is given up and has to be reconciled by hand.
+	if p.Reference == "" {
+		result := payment.Result{Status: payment.StatusFailed, FailureReason: "not submitted to the payment provider"}
+		return d.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			_, err := ApplyPaymentResult(tx, p.ID, result, time.Now())
+			return err
+		})
+	}
+	result, err := d.Payments.Status(ctx, p.Reference)
+	if err != nil {
+		return err
+	}
+	if result.Status == payment.StatusPending {
+		if time.Since(p.CreatedAt) < maxSettleWait {
+			return errPaymentPending
+		}
+		result = payment.Result{Status: payment.StatusFailed, FailureReason: "not settled within 7 days"}
+	}
+	return d.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		_, err := ApplyPaymentResult(tx, p.ID, result, time.Now())
+		return err
+	})
+}
+
+// ChargeDueInstallment books the installment as owed by the tenant once it is due. It
+// does nothing for installments that aren't scheduled anymore.
+func ChargeDueInstallment(tx *gorm.DB, installmentID uint, now time.Time) error {
+	var installment models.RentInstallment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&installment, installmentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if installment.Status != models.RentInstallmentStatusScheduled || installment.DueDate.After(now) {
+		return nil
+	}
+
+	description := fmt.Sprintf("rent installment %d", installment.Number)
+	journal := models.NewLedgerJournal(installment.LeaseID, models.LedgerKindCharge, description,
+		models.LedgerAccountTenantReceivable, models.LedgerAccountRentIncome, installment.Amount)
+	journal.InstallmentID = &installment.ID
+	if err := models.PostLedgerJournal(tx, &journal); err != nil {
+		return err
+	}
+	installment.Status = models.RentInstallmentStatusCharged
+	installment.ChargedAt = &now
+	return tx.Model(&installment).Select("status", "charged_at").Updates(&installment).Error
+}
+
+// ApplyPaymentResult records the provider's answer for a pending payment. A successful
+// payment is booked against what the tenant owes. Payments that aren't pending anymore
+// are returned unchanged.
+func ApplyPaymentResult(tx *gorm.DB, paymentID uint, result payment.Result, now time.Time) (models.Payment, error) {
+	var p models.Payment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&p, paymentID).Error; err != nil {
+		return p, err
+	}
+	if p.Status != models.PaymentStatusPending {
+		return p, nil
+	}
+	if result.Reference != "" {
+		p.Reference = result.Reference
+	}
+
+	switch result.Status {
+	case payment.StatusPending:
+		return p, tx.Model(&p).Update("reference", p.Reference).Error
+	case payment.StatusSucceeded:
+		journal := models.NewLedgerJournal(p.LeaseID, models.LedgerKindPayment, p.Method+" payment",
+			models.LedgerAccountCash, models.LedgerAccountTenantReceivable, p.Amount)
+		journal.PaymentID = &p.ID
+		if err := models.PostLedgerJournal(tx, &journal); err != nil {
+			return p, err
+		}
+		p.Status = models.PaymentStatusSucceeded
+		p.SettledAt = &now
+	default:
+		p.Status = models.PaymentStatusFailed
+		p.FailureReason = result.FailureReason
+	}
+	err := tx.Model(&p).Select("reference", "status", "failure_reason", "settled_at").Updates(&p).Error
+	return p, err
+}
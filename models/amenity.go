@@ -0,0 +1,9 @@
+package models
+
+import "gorm.io/gorm"
+
+type Amenity struct {
+	gorm.Model
+	ID   uint   `gorm:"primarykey"`
+	Name string `json:"name" gorm:"unique"`
+}
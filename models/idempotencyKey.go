@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// IdempotencyKey stores the response to a request sent with an Idempotency-Key header, so
+// a retry gets the same response instead of repeating the request. StatusCode is 0 while
+// the first request is still being handled.
+type IdempotencyKey struct {
+	gorm.Model
+	ID          uint      `gorm:"primarykey"`
+	UserID      uint      `json:"userId" gorm:"uniqueIndex:idx_idempotency_keys_user_key"`
+	Key         string    `json:"key" gorm:"uniqueIndex:idx_idempotency_keys_user_key"`
+	RequestHash string    `json:"-"`
+	StatusCode  int       `json:"statusCode"`
+	Response    []byte    `json:"-"`
+	ExpiresAt   time.Time `json:"expiresAt" gorm:"index"`
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Lease states. A lease stays active until its end date, also when it was terminated
+// early, which moves the end date forward.
+const (
+	LeaseStatusActive     = "active"
+	LeaseStatusEnded      = "ended"
+	LeaseStatusTerminated = "terminated"
+)
+
+// Lease holds the terms of a completed rental transaction
+type Lease struct {
+	gorm.Model
+	ID            uint      `gorm:"primarykey"`
+	TransactionID uint      `json:"transactionId" gorm:"uniqueIndex"`
+	PropertyID    uint      `json:"propertyId" gorm:"index"`
+	TenantID      uint      `json:"tenantId" gorm:"index"`
+	OwnerID       uint      `json:"ownerId" gorm:"index"`
+	StartDate     time.Time `json:"startDate"`
+	EndDate       time.Time `json:"endDate" gorm:"index"`
+	MonthlyRent   float32   `json:"monthlyRent"`
+	Deposit       float32   `json:"deposit"`
+	// RenewalMonths is how much a renewal extends the lease by, 0 if it can't be renewed
+	RenewalMonths int `json:"renewalMonths"`
+	// MaxRenewals limits how often the lease can be renewed, 0 means no limit
+	MaxRenewals       int               `json:"maxRenewals"`
+	Renewals          int               `json:"renewals"`
+	Status            string            `json:"status" gorm:"index"`
+	TerminatedAt      *time.Time        `json:"terminatedAt"`
+	TerminatedByID    *uint             `json:"terminatedById"`
+	TerminationReason string            `json:"terminationReason"`
+	Transaction       Transaction       `json:"transaction"`
+	Property          Property          `json:"property"`
+	Installments      []RentInstallment `json:"installments"`
+}
+
+// Renewable reports whether the lease can be renewed once more
+func (l Lease) Renewable() bool {
+	return l.Status == LeaseStatusActive && l.TerminatedAt == nil && l.RenewalMonths > 0 &&
+		(l.MaxRenewals == 0 || l.Renewals < l.MaxRenewals)
+}
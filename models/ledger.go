@@ -0,0 +1,106 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Ledger accounts kept per lease. The tenant's receivable is what the tenant owes, the
+// deposit is held for the tenant until it is refunded and cash is what went through the
+// payment provider.
+const (
+	LedgerAccountTenantReceivable = "tenant_receivable"
+	LedgerAccountRentIncome       = "rent_income"
+	LedgerAccountDepositHeld      = "deposit_held"
+	LedgerAccountCash             = "cash"
+)
+
+// Kinds of ledger journals
+const (
+	LedgerKindCharge  = "charge"
+	LedgerKindDeposit = "deposit"
+	LedgerKindPayment = "payment"
+	LedgerKindRefund  = "refund"
+)
+
+// ErrUnbalancedJournal is returned for journals whose entries don't sum to zero
+var ErrUnbalancedJournal = errors.New("ledger journal is not balanced")
+
+// LedgerJournal is one booking of a lease, its entries always sum to zero
+type LedgerJournal struct {
+	gorm.Model
+	ID            uint          `gorm:"primarykey"`
+	LeaseID       uint          `json:"leaseId" gorm:"index"`
+	Kind          string        `json:"kind"`
+	Description   string        `json:"description"`
+	InstallmentID *uint         `json:"installmentId" gorm:"index"`
+	PaymentID     *uint         `json:"paymentId" gorm:"index"`
+	Entries       []LedgerEntry `json:"entries" gorm:"foreignKey:JournalID"`
+}
+
+// LedgerEntry moves money into or out of an account. Amounts are in cents, debits are
+// positive and credits negative.
+type LedgerEntry struct {
+	ID        uint      `gorm:"primarykey"`
+	CreatedAt time.Time `json:"createdAt"`
+	JournalID uint      `json:"journalId" gorm:"index"`
+	LeaseID   uint      `json:"leaseId" gorm:"index:idx_ledger_entries_lease_account"`
+	Account   string    `json:"account" gorm:"index:idx_ledger_entries_lease_account"`
+	Amount    int64     `json:"amount"`
+}
+
+// NewLedgerJournal books amount from the credited to the debited account
+func NewLedgerJournal(leaseID uint, kind, description, debit, credit string, amount int64) LedgerJournal {
+	return LedgerJournal{
+		LeaseID:     leaseID,
+		Kind:        kind,
+		Description: description,
+		Entries: []LedgerEntry{
+			{LeaseID: leaseID, Account: debit, Amount: amount},
+			{LeaseID: leaseID, Account: credit, Amount: -amount},
+		},
+	}
+}
+
+// Balanced reports whether the journal has entries and they sum to zero
+func (j LedgerJournal) Balanced() bool {
+	var sum int64
+	for _, entry := range j.Entries {
+		if entry.LeaseID != j.LeaseID {
+			return false
+		}
+		sum += entry.Amount
+	}
+	return len(j.Entries) > 0 && sum == 0
+}
+
+// PostLedgerJournal stores the journal with its entries, refusing unbalanced ones
+func PostLedgerJournal(tx *gorm.DB, journal *LedgerJournal) error {
+	if !journal.Balanced() {
+		return ErrUnbalancedJournal
+	}
+	return tx.Create(journal).Error
+}
+
+// LedgerBalances sums the entries of the lease per account
+func LedgerBalances(db *gorm.DB, leaseID uint) (map[string]int64, error) {
+	var rows []struct {
+		Account string
+		Balance int64
+	}
+	err := db.Model(&LedgerEntry{}).Select("account, SUM(amount) AS balance").
+		Where("lease_id = ?", leaseID).Group("account").Scan(&rows).Error
+	balances := map[string]int64{}
+	for _, row := range rows {
+		balances[row.Account] = row.Balance
+	}
+	return balances, err
+}
+
+// Cents converts a price to cents
+func Cents(price float32) int64 {
+	return int64(math.Round(float64(price) * 100))
+}
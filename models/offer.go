@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Offer states. Only pending offers can be answered, a counter-offer replaces the offer
+// it answers, which becomes countered.
+const (
+	OfferStatusPending   = "pending"
+	OfferStatusAccepted  = "accepted"
+	OfferStatusRejected  = "rejected"
+	OfferStatusCountered = "countered"
+	OfferStatusWithdrawn = "withdrawn"
+	OfferStatusExpired   = "expired"
+)
+
+// Offer is a price and terms proposed for a property by a client or, when countering, by
+// the owner. The party that didn't propose it can accept, reject or counter it.
+type Offer struct {
+	gorm.Model
+	ID           uint       `gorm:"primarykey"`
+	PropertyID   uint       `json:"propertyId" gorm:"index"`
+	ClientID     uint       `json:"clientId" gorm:"index"`
+	OwnerID      uint       `json:"ownerId" gorm:"index"`
+	ProposedByID uint       `json:"proposedById"`
+	// CountersID is the offer this one answers
+	CountersID   *uint      `json:"countersId"`
+	Type         string     `json:"type"` // TransactionTypeBuy or TransactionTypeRent
+	Price        float32    `json:"price"`
+	Terms        string     `json:"terms"`
+	Status       string     `json:"status" gorm:"index"`
+	ExpiresAt    time.Time  `json:"expiresAt" gorm:"index"`
+	RespondedAt  *time.Time `json:"respondedAt"`
+	Property     Property   `json:"property"`
+}
+
+// Open reports whether the offer can still be answered
+func (o Offer) Open(now time.Time) bool {
+	return o.Status == OfferStatusPending && now.Before(o.ExpiresAt)
+}
+
+// RespondentID is the user who has to answer the offer
+func (o Offer) RespondentID() uint {
+	if o.ProposedByID == o.ClientID {
+		return o.OwnerID
+	}
+	return o.ClientID
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOfferOpen(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		status    string
+		expiresAt time.Time
+		want      bool
+	}{
+		{OfferStatusPending, now.Add(time.Hour), true},
+		{OfferStatusPending, now, false},
+		{OfferStatusPending, now.Add(-time.Second), false},
+		{OfferStatusAccepted, now.Add(time.Hour), false},
+		{OfferStatusRejected, now.Add(time.Hour), false},
+		{OfferStatusCountered, now.Add(time.Hour), false},
+		{OfferStatusWithdrawn, now.Add(time.Hour), false},
+		{OfferStatusExpired, now.Add(time.Hour), false},
+	}
+	for _, tt := range tests {
+		offer := Offer{Status: tt.status, ExpiresAt: tt.expiresAt}
+		if got := offer.Open(now); got != tt.want {
+			t.Errorf("%s offer expiring %s: got open %v, want %v", tt.status, tt.expiresAt.Sub(now), got, tt.want)
+		}
+	}
+}
+
+func TestOfferRespondentID(t *testing.T) {
+	const client, owner = 10, 20
+
+	// The client's offer is answered by the owner, and every counter-offer switches sides
+	offer := Offer{ClientID: client, OwnerID: owner, ProposedByID: client}
+	want := []uint{owner, client, owner, client}
+	for round, respondent := range want {
+		if got := offer.RespondentID(); got != respondent {
+			t.Fatalf("round %d: got respondent %d, want %d", round, got, respondent)
+		}
+		offer = Offer{ClientID: offer.ClientID, OwnerID: offer.OwnerID, ProposedByID: offer.RespondentID()}
+	}
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PaymentMethodManual is a payment the owner received outside of the payment provider,
+// e.g. in cash. Card and bank payments go through the provider.
+const PaymentMethodManual = "manual"
+
+// Payment states
+const (
+	PaymentStatusPending   = "pending"
+	PaymentStatusSucceeded = "succeeded"
+	PaymentStatusFailed    = "failed"
+)
+
+// Payment is money the tenant paid towards a lease, amounts are in cents
+type Payment struct {
+	gorm.Model
+	ID            uint       `gorm:"primarykey"`
+	LeaseID       uint       `json:"leaseId" gorm:"index"`
+	PayerID       uint       `json:"payerId"`
+	RecordedByID  uint       `json:"recordedById"`
+	Amount        int64      `json:"amount"`
+	Refunded      int64      `json:"refunded"`
+	Method        string     `json:"method"`
+	Reference     string     `json:"reference" gorm:"index"`
+	Status        string     `json:"status" gorm:"index"`
+	FailureReason string     `json:"failureReason"`
+	SettledAt     *time.Time `json:"settledAt"`
+}
+
+// Refundable is the part of the payment that hasn't been refunded yet
+func (p Payment) Refundable() int64 {
+	if p.Status != PaymentStatusSucceeded {
+		return 0
+	}
+	return p.Amount - p.Refunded
+}
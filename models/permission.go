@@ -0,0 +1,36 @@
+package models
+
+import "gorm.io/gorm"
+
+// Permission names checked by the API
+const (
+	PermissionPropertyCreate    = "property:create"
+	PermissionPropertyUpdate    = "property:update"
+	PermissionPropertyDelete    = "property:delete"
+	PermissionPropertyManageAny = "property:manage_any"
+	PermissionPropertyReview    = "property:review"
+	PermissionCategoryManage    = "category:manage"
+	PermissionTypeManage        = "type:manage"
+	PermissionAmenityManage     = "amenity:manage"
+	PermissionRoleManage        = "role:manage"
+)
+
+// DefaultPermissions lists every permission the API knows about with its description
+var DefaultPermissions = map[string]string{
+	PermissionPropertyCreate:    "Create property listings",
+	PermissionPropertyUpdate:    "Update property listings",
+	PermissionPropertyDelete:    "Delete property listings",
+	PermissionPropertyManageAny: "Update or delete listings owned by other users",
+	PermissionPropertyReview:    "Approve or reject listings submitted for review and withdraw listings",
+	PermissionCategoryManage:    "Manage property categories",
+	PermissionTypeManage:        "Manage property types",
+	PermissionAmenityManage:     "Manage amenities",
+	PermissionRoleManage:        "Manage roles and their permissions",
+}
+
+type Permission struct {
+	gorm.Model
+	ID          uint   `gorm:"primarykey"`
+	Name        string `json:"name" gorm:"unique"`
+	Description string `json:"description"`
+}
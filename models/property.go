@@ -1,20 +1,73 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"slices"
+
+	"gorm.io/gorm"
+)
+
+// Listing modes, how a property is offered
+const (
+	ListingModeSale = "sale"
+	ListingModeRent = "rent"
+	ListingModeBoth = "both"
+)
+
+// Transaction types a client can choose between
+const (
+	TransactionTypeBuy  = "buy"
+	TransactionTypeRent = "rent"
+)
+
+// listingModeTransactionTypes maps each listing mode to the transactions it allows
+var listingModeTransactionTypes = map[string][]string{
+	ListingModeSale: {TransactionTypeBuy},
+	ListingModeRent: {TransactionTypeRent},
+	ListingModeBoth: {TransactionTypeBuy, TransactionTypeRent},
+}
 
 type Property struct {
 	gorm.Model
 	ID                 uint             `gorm:"primarykey"`
 	Name               string           `json:"name"`
 	Description        string           `json:"description"`
-	Status             string           `json:"status" gorm:"default:available"`
+	Status             string           `json:"status" gorm:"default:draft"`
+	// Version is incremented by every edit, stale edits are rejected by comparing it
+	Version            uint             `json:"version" gorm:"not null;default:1"`
+	ListingMode        string           `json:"listingMode" gorm:"index"`
 	Price              float32          `json:"price"`
 	Location           string           `json:"location"`
+	Latitude           *float64         `json:"latitude" gorm:"index:idx_properties_coordinates"`
+	Longitude          *float64         `json:"longitude" gorm:"index:idx_properties_coordinates"`
+	Bedrooms           *int             `json:"bedrooms" gorm:"index"`
+	Bathrooms          *int             `json:"bathrooms"`
+	Area               *float64         `json:"area" gorm:"index"`
+	Floor              *int             `json:"floor"`
+	YearBuilt          *int             `json:"yearBuilt"`
+	Amenities          []Amenity        `json:"amenities" gorm:"many2many:property_amenities;"`
 	OwnerID            uint             `json:"ownerId"`
 	Owner              User             `json:"owner" gorm:"foreignKey:OwnerID;references:ID"`
 	ImagePrefix           string        `json:"imagePrefix"`
+	Images             []PropertyImage  `json:"images"`
 	PropertyTypeID     uint             `json:"propertyTypeId"`
 	PropertyType       PropertyType     `json:"propertyType"`
 	PropertyCategoryID uint             `json:"propertyCategoryId"`
 	PropertyCategory   PropertyCategory `json:"propertyCategory"`
+	SearchVector       string           `json:"-" gorm:"type:tsvector;->:false;<-:false;index:idx_properties_search_vector,type:gin"`
+}
+
+// IsListingMode reports whether the mode is a known listing mode
+func IsListingMode(mode string) bool {
+	_, ok := listingModeTransactionTypes[mode]
+	return ok
+}
+
+// TransactionTypes returns the transaction types the property's listing mode allows
+func (p Property) TransactionTypes() []string {
+	return listingModeTransactionTypes[p.ListingMode]
+}
+
+// AllowsTransactionType reports whether the property can be bought or rented as requested
+func (p Property) AllowsTransactionType(transactionType string) bool {
+	return slices.Contains(p.TransactionTypes(), transactionType)
 }
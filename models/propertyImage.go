@@ -0,0 +1,28 @@
+package models
+
+import "gorm.io/gorm"
+
+// PropertyImage is an image stored under the property's ImagePrefix
+type PropertyImage struct {
+	gorm.Model
+	ID          uint   `gorm:"primarykey"`
+	PropertyID  uint   `json:"propertyId" gorm:"index"`
+	Key         string `json:"key" gorm:"unique"`
+	ContentType string `json:"contentType"`
+	Size        int64  `json:"size"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Position    int    `json:"position"`
+	IsCover     bool   `json:"isCover"`
+	// Renditions maps the rendition name, such as thumbnail, to its key
+	Renditions map[string]string `json:"renditions" gorm:"serializer:json;type:jsonb"`
+}
+
+// StorageKeys returns the keys of the original and its renditions
+func (i PropertyImage) StorageKeys() []string {
+	keys := []string{i.Key}
+	for _, key := range i.Renditions {
+		keys = append(keys, key)
+	}
+	return keys
+}
@@ -0,0 +1,157 @@
+package models
+
+import (
+	"errors"
+	"slices"
+
+	"gorm.io/gorm"
+)
+
+// Listing states of a property
+const (
+	PropertyStatusDraft         = "draft"
+	PropertyStatusPendingReview = "pending_review"
+	PropertyStatusAvailable     = "available"
+	PropertyStatusUnderOffer    = "under_offer"
+	PropertyStatusReserved      = "reserved"
+	PropertyStatusRented        = "rented"
+	PropertyStatusSold          = "sold"
+	PropertyStatusWithdrawn     = "withdrawn"
+)
+
+// PublicPropertyStatuses are the states in which a listing is shown to everyone, in the
+// others only its owner and staff see it
+var PublicPropertyStatuses = []string{PropertyStatusAvailable, PropertyStatusUnderOffer, PropertyStatusReserved}
+
+// PropertyActor is the kind of user a status transition is made by
+type PropertyActor string
+
+const (
+	// PropertyActorOwner is the owner, or a user who may manage any listing
+	PropertyActorOwner PropertyActor = "owner"
+	// PropertyActorReviewer is a user with PermissionPropertyReview
+	PropertyActorReviewer PropertyActor = "reviewer"
+	// PropertyActorSystem is the API itself, e.g. when a transaction is made
+	PropertyActorSystem PropertyActor = "system"
+)
+
+var (
+	ErrInvalidStatusTransition   = errors.New("invalid status transition")
+	ErrStatusTransitionForbidden = errors.New("status transition not allowed")
+	ErrPropertyStatusChanged     = errors.New("property status changed concurrently")
+)
+
+// propertyTransitions lists who may move a listing from one state to another
+var propertyTransitions = map[string]map[string][]PropertyActor{
+	PropertyStatusDraft: {
+		PropertyStatusPendingReview: {PropertyActorOwner},
+		PropertyStatusWithdrawn:     {PropertyActorOwner},
+	},
+	PropertyStatusPendingReview: {
+		PropertyStatusAvailable: {PropertyActorReviewer},
+		PropertyStatusDraft:     {PropertyActorReviewer, PropertyActorOwner},
+		PropertyStatusWithdrawn: {PropertyActorOwner},
+	},
+	PropertyStatusAvailable: {
+		PropertyStatusUnderOffer: {PropertyActorSystem},
+		PropertyStatusReserved:   {PropertyActorOwner, PropertyActorSystem},
+		PropertyStatusRented:     {PropertyActorSystem},
+		PropertyStatusSold:       {PropertyActorSystem},
+		PropertyStatusWithdrawn:  {PropertyActorOwner, PropertyActorReviewer},
+	},
+	PropertyStatusUnderOffer: {
+		PropertyStatusAvailable: {PropertyActorSystem},
+		PropertyStatusReserved:  {PropertyActorOwner, PropertyActorSystem},
+		PropertyStatusRented:    {PropertyActorSystem},
+		PropertyStatusSold:      {PropertyActorSystem},
+		PropertyStatusWithdrawn: {PropertyActorOwner, PropertyActorReviewer},
+	},
+	PropertyStatusReserved: {
+		PropertyStatusAvailable: {PropertyActorOwner, PropertyActorSystem},
+		PropertyStatusRented:    {PropertyActorSystem},
+		PropertyStatusSold:      {PropertyActorSystem},
+		PropertyStatusWithdrawn: {PropertyActorOwner, PropertyActorReviewer},
+	},
+	// Relisting goes through review again
+	PropertyStatusRented: {
+		PropertyStatusPendingReview: {PropertyActorOwner},
+		PropertyStatusAvailable:     {PropertyActorSystem},
+	},
+	PropertyStatusWithdrawn: {
+		PropertyStatusPendingReview: {PropertyActorOwner},
+	},
+	PropertyStatusSold: {},
+}
+
+// IsPropertyStatus reports whether the status is a known listing state
+func IsPropertyStatus(status string) bool {
+	_, ok := propertyTransitions[status]
+	return ok
+}
+
+// IsPublicPropertyStatus reports whether listings in the state are shown to everyone
+func IsPublicPropertyStatus(status string) bool {
+	return slices.Contains(PublicPropertyStatuses, status)
+}
+
+// CheckPropertyTransition returns the first of the actors that may move a listing from
+// one state to the other. It fails with ErrInvalidStatusTransition if the move isn't
+// possible at all and with ErrStatusTransitionForbidden if none of the actors may make it.
+func CheckPropertyTransition(from, to string, actors ...PropertyActor) (PropertyActor, error) {
+	allowed, ok := propertyTransitions[from][to]
+	if !ok {
+		return "", ErrInvalidStatusTransition
+	}
+	for _, actor := range actors {
+		if slices.Contains(allowed, actor) {
+			return actor, nil
+		}
+	}
+	return "", ErrStatusTransitionForbidden
+}
+
+// PropertyStatusChange records a change of a property's status. ActorID is nil when the
+// change wasn't made on behalf of a user.
+type PropertyStatusChange struct {
+	gorm.Model
+	ID         uint          `gorm:"primarykey"`
+	PropertyID uint          `json:"propertyId" gorm:"index"`
+	From       string        `json:"from"`
+	To         string        `json:"to"`
+	ActorID    *uint         `json:"actorId"`
+	Actor      PropertyActor `json:"actor"`
+	Note       string        `json:"note"`
+}
+
+// ChangePropertyStatus moves the property to another status if one of the actors may,
+// and records the change. It fails if the status was changed since the property was read.
+func ChangePropertyStatus(tx *gorm.DB, property *Property, to string, actors []PropertyActor, actorID *uint, note string) error {
+	actor, err := CheckPropertyTransition(property.Status, to, actors...)
+	if err != nil {
+		return err
+	}
+	result := tx.Model(&Property{}).Where("id = ? AND status = ?", property.ID, property.Status).Update("status", to)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrPropertyStatusChanged
+	}
+	if err := RecordPropertyStatus(tx, property.ID, property.Status, to, actor, actorID, note); err != nil {
+		return err
+	}
+	property.Status = to
+	return nil
+}
+
+// RecordPropertyStatus adds an entry to the status history of a property
+func RecordPropertyStatus(tx *gorm.DB, propertyID uint, from, to string, actor PropertyActor, actorID *uint, note string) error {
+	return tx.Create(&PropertyStatusChange{
+		PropertyID: propertyID,
+		From:       from,
+		To:         to,
+		ActorID:    actorID,
+		Actor:      actor,
+		Note:       note,
+	}).Error
+}
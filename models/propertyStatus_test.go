@@ -0,0 +1,41 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckPropertyTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		actors   []PropertyActor
+		want     PropertyActor
+		err      error
+	}{
+		{PropertyStatusDraft, PropertyStatusPendingReview, []PropertyActor{PropertyActorOwner}, PropertyActorOwner, nil},
+		{PropertyStatusPendingReview, PropertyStatusAvailable, []PropertyActor{PropertyActorOwner}, "", ErrStatusTransitionForbidden},
+		{PropertyStatusPendingReview, PropertyStatusAvailable, []PropertyActor{PropertyActorOwner, PropertyActorReviewer}, PropertyActorReviewer, nil},
+		{PropertyStatusAvailable, PropertyStatusSold, []PropertyActor{PropertyActorOwner}, "", ErrStatusTransitionForbidden},
+		{PropertyStatusAvailable, PropertyStatusSold, []PropertyActor{PropertyActorSystem}, PropertyActorSystem, nil},
+		{PropertyStatusSold, PropertyStatusAvailable, []PropertyActor{PropertyActorSystem}, "", ErrInvalidStatusTransition},
+		{PropertyStatusDraft, PropertyStatusAvailable, []PropertyActor{PropertyActorReviewer}, "", ErrInvalidStatusTransition},
+		{"unknown", PropertyStatusAvailable, []PropertyActor{PropertyActorSystem}, "", ErrInvalidStatusTransition},
+	}
+	for _, tt := range tests {
+		actor, err := CheckPropertyTransition(tt.from, tt.to, tt.actors...)
+		if actor != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("%s -> %s by %v: got %q, %v, want %q, %v", tt.from, tt.to, tt.actors, actor, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestPublicPropertyStatuses(t *testing.T) {
+	for _, status := range PublicPropertyStatuses {
+		if !IsPropertyStatus(status) {
+			t.Errorf("public status %q is not a known status", status)
+		}
+	}
+	if IsPublicPropertyStatus(PropertyStatusDraft) || IsPublicPropertyStatus(PropertyStatusWithdrawn) {
+		t.Error("drafts and withdrawn listings must not be public")
+	}
+}
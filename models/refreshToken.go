@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RefreshToken is a single-use token that can be exchanged for a new access token.
+// Tokens issued from the same login share a FamilyID so that reuse of a rotated
+// token can revoke the whole session.
+type RefreshToken struct {
+	gorm.Model
+	ID        uint       `gorm:"primarykey"`
+	UserID    uint       `json:"userId" gorm:"index"`
+	User      User       `json:"-"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex"`
+	FamilyID  string     `json:"familyId" gorm:"index"`
+	AccessJTI string     `json:"-"`
+	ExpiresAt time.Time  `json:"expiresAt"`
+	RevokedAt *time.Time `json:"revokedAt"`
+}
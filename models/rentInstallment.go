@@ -0,0 +1,82 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Installment states. A scheduled installment is charged to the tenant on its due date,
+// installments after the end of a terminated lease are cancelled.
+const (
+	RentInstallmentStatusScheduled = "scheduled"
+	RentInstallmentStatusCharged   = "charged"
+	RentInstallmentStatusCancelled = "cancelled"
+)
+
+// RentInstallment is the rent for one month of a lease, amounts are in cents
+type RentInstallment struct {
+	gorm.Model
+	ID        uint       `gorm:"primarykey"`
+	LeaseID   uint       `json:"leaseId" gorm:"index"`
+	Number    int        `json:"number"`
+	DueDate   time.Time  `json:"dueDate" gorm:"index"`
+	PeriodEnd time.Time  `json:"periodEnd"`
+	Amount    int64      `json:"amount"`
+	Status    string     `json:"status" gorm:"index"`
+	ChargedAt *time.Time `json:"chargedAt"`
+}
+
+// RentSchedule returns the monthly installments of the lease that are due from the given
+// time until the lease ends. Months count from the start date, and every started month
+// is charged in full.
+func RentSchedule(lease Lease, from time.Time) []RentInstallment {
+	var installments []RentInstallment
+	for i := 0; ; i++ {
+		due := AddMonths(lease.StartDate, i)
+		if !due.Before(lease.EndDate) {
+			return installments
+		}
+		if due.Before(from) {
+			continue
+		}
+		periodEnd := AddMonths(lease.StartDate, i+1)
+		if periodEnd.After(lease.EndDate) {
+			periodEnd = lease.EndDate
+		}
+		installments = append(installments, RentInstallment{
+			LeaseID:   lease.ID,
+			Number:    i + 1,
+			DueDate:   due,
+			PeriodEnd: periodEnd,
+			Amount:    Cents(lease.MonthlyRent),
+			Status:    RentInstallmentStatusScheduled,
+		})
+	}
+}
+
+// AddMonths moves t by the number of months, keeping the day of the month unless the
+// target month is shorter. Unlike time.AddDate, Jan 31 plus one month is Feb 28 (or 29)
+// and not Mar 3.
+func AddMonths(t time.Time, months int) time.Time {
+	year, month, day := t.Date()
+	hour, minute, sec := t.Clock()
+	first := time.Date(year, month+time.Month(months), 1, hour, minute, sec, t.Nanosecond(), t.Location())
+	lastDay := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(day, lastDay)-1)
+}
+
+// AllocateRentPayments spreads what the tenant paid over the deposit and then over the
+// charged installments, the oldest first. It returns the amount paid of each installment.
+func AllocateRentPayments(deposit int64, installments []RentInstallment, paid int64) []int64 {
+	allocated := make([]int64, len(installments))
+	paid -= min(paid, deposit)
+	for i, installment := range installments {
+		if installment.Status != RentInstallmentStatusCharged {
+			continue
+		}
+		allocated[i] = min(paid, installment.Amount)
+		paid -= allocated[i]
+	}
+	return allocated
+}
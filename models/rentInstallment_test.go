@@ -0,0 +1,97 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRentSchedule(t *testing.T) {
+	start := time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)
+	lease := Lease{StartDate: start, EndDate: start.AddDate(0, 2, 10), MonthlyRent: 1200.5}
+
+	installments := RentSchedule(lease, start)
+	if len(installments) != 3 {
+		t.Fatalf("got %d installments, want 3", len(installments))
+	}
+	last := installments[2]
+	if last.Number != 3 || !last.DueDate.Equal(start.AddDate(0, 2, 0)) || !last.PeriodEnd.Equal(lease.EndDate) {
+		t.Fatalf("unexpected last installment %+v", last)
+	}
+	if last.Amount != 120050 || last.Status != RentInstallmentStatusScheduled {
+		t.Fatalf("got amount %d and status %s", last.Amount, last.Status)
+	}
+
+	// A renewal only adds the months after the previous end
+	renewed := lease
+	renewed.EndDate = lease.EndDate.AddDate(0, 2, 0)
+	installments = RentSchedule(renewed, lease.EndDate)
+	if len(installments) != 2 || installments[0].Number != 4 {
+		t.Fatalf("got %+v, want installments 4 and 5", installments)
+	}
+}
+
+func TestRentScheduleAtMonthEnd(t *testing.T) {
+	tests := []struct {
+		start time.Time
+		want  []string
+	}{
+		{
+			start: time.Date(2026, 1, 31, 12, 0, 0, 0, time.UTC),
+			want:  []string{"2026-01-31", "2026-02-28", "2026-03-31", "2026-04-30"},
+		},
+		{
+			start: time.Date(2028, 2, 29, 12, 0, 0, 0, time.UTC),
+			want:  []string{"2028-02-29", "2028-03-29", "2028-04-29", "2028-05-29"},
+		},
+		{
+			start: time.Date(2027, 12, 31, 12, 0, 0, 0, time.UTC),
+			want:  []string{"2027-12-31", "2028-01-31", "2028-02-29", "2028-03-31"},
+		},
+	}
+	for _, tt := range tests {
+		lease := Lease{StartDate: tt.start, EndDate: AddMonths(tt.start, len(tt.want)), MonthlyRent: 1000}
+		installments := RentSchedule(lease, tt.start)
+		if len(installments) != len(tt.want) {
+			t.Fatalf("start %s: got %d installments, want %d", tt.start, len(installments), len(tt.want))
+		}
+		for i, want := range tt.want {
+			if got := installments[i].DueDate.Format("2006-01-02"); got != want {
+				t.Fatalf("start %s: installment %d is due %s, want %s", tt.start, i+1, got, want)
+			}
+			if i > 0 && !installments[i-1].PeriodEnd.Equal(installments[i].DueDate) {
+				t.Fatalf("start %s: installment %d doesn't start where the previous one ended", tt.start, i+1)
+			}
+		}
+	}
+
+	// A year from Feb 29 ends on Feb 28 of the next year
+	if got := AddMonths(time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC), 12).Format("2006-01-02"); got != "2029-02-28" {
+		t.Fatalf("got %s, want 2029-02-28", got)
+	}
+}
+
+func TestAllocateRentPayments(t *testing.T) {
+	installments := []RentInstallment{
+		{Amount: 1000, Status: RentInstallmentStatusCharged},
+		{Amount: 1000, Status: RentInstallmentStatusCharged},
+		{Amount: 1000, Status: RentInstallmentStatusScheduled},
+	}
+	allocated := AllocateRentPayments(500, installments, 2000)
+	want := []int64{1000, 500, 0}
+	for i := range want {
+		if allocated[i] != want[i] {
+			t.Fatalf("got %v, want %v", allocated, want)
+		}
+	}
+}
+
+func TestLedgerJournalBalanced(t *testing.T) {
+	journal := NewLedgerJournal(1, LedgerKindPayment, "", LedgerAccountCash, LedgerAccountTenantReceivable, 1000)
+	if !journal.Balanced() {
+		t.Fatal("journal should be balanced")
+	}
+	journal.Entries[0].Amount++
+	if journal.Balanced() {
+		t.Fatal("journal should not be balanced")
+	}
+}
@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RevokedToken is a denylist entry for an access token that was revoked before it expired.
+type RevokedToken struct {
+	gorm.Model
+	ID        uint      `gorm:"primarykey"`
+	JTI       string    `json:"jti" gorm:"uniqueIndex"`
+	ExpiresAt time.Time `json:"expiresAt" gorm:"index"`
+}
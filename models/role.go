@@ -2,8 +2,13 @@ package models
 
 import "gorm.io/gorm"
 
+// DefaultRoleName is the role users get when they sign up, other roles are assigned by
+// users with the role:manage permission
+const DefaultRoleName = "user"
+
 type Role struct {
 	gorm.Model
-	ID   uint   `gorm:"primarykey"`
-	Name string `json:"name" gorm:"unique"`
+	ID          uint         `gorm:"primarykey"`
+	Name        string       `json:"name" gorm:"unique"`
+	Permissions []Permission `json:"permissions" gorm:"many2many:role_permissions;"`
 }
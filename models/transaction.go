@@ -1,6 +1,18 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Transaction states, a transaction stays pending until the owner completes the sale or
+// rental, or one of the parties cancels it
+const (
+	TransactionStatusPending   = "pending"
+	TransactionStatusCompleted = "completed"
+	TransactionStatusCancelled = "cancelled"
+)
 
 type Transaction struct {
 	gorm.Model
@@ -8,7 +20,12 @@ type Transaction struct {
 	ClientID   uint     `json:"clientId"`
 	OwnerID    uint     `json:"ownerId"`
 	PropertyID uint     `json:"propertyId"`
-	Type       string   `json:"type"` // "rent" or "buy"
+	Type       string   `json:"type"` // TransactionTypeBuy or TransactionTypeRent
+	Price      float32  `json:"price"`
+	Status     string   `json:"status" gorm:"default:completed;index"`
+	// OfferID is the accepted offer the transaction was made from, if any
+	OfferID    *uint    `json:"offerId"`
+	ClosedAt   *time.Time `json:"closedAt"`
 	Client     User     `json:"client" gorm:"foreignKey:ClientID;references:ID"`
 	Owner      User     `json:"owner" gorm:"foreignKey:OwnerID;references:ID"`
 	Property   Property `json:"property"`
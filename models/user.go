@@ -8,16 +8,7 @@ type User struct {
 	ID       uint   `gorm:"primarykey"`
 	Name     string `json:"name"`
 	Email    string `json:"email" gorm:"unique"`
-	Password string `json:"password"`
+	Password string `json:"-"`
 	RoleID   uint   `json:"roleId" gorm:"default:1"`
 	Role     Role
 }
-
-func (u *User) Serialize() *map[string]interface{} {
-	return &map[string]interface{}{
-		"name":   u.Name,
-		"email":  u.Email,
-		"roleId": u.RoleID,
-		"id":     u.ID,
-	}
-}
@@ -0,0 +1,128 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sources the fake driver treats specially, any other source succeeds
+const (
+	FakeCardDeclined          = "card_declined"
+	FakeCardInsufficientFunds = "card_insufficient_funds"
+	FakeBankInsufficientFunds = "bank_insufficient_funds"
+	// FakeUnavailable makes the request fail with ErrUnavailable
+	FakeUnavailable = "unavailable"
+)
+
+// Fake simulates a provider for local development and tests. Card payments are decided
+// at once, bank transfers settle SettleAfter after they were made. Everything it needs is
+// encoded in the reference, so an API and a worker process agree on the outcome.
+type Fake struct {
+	SettleAfter time.Duration
+	// Now is the clock, tests can replace it
+	Now func() time.Time
+
+	mu        sync.Mutex
+	processed map[string]Result
+}
+
+func NewFake() *Fake {
+	return &Fake{
+		SettleAfter: 30 * time.Second,
+		Now:         time.Now,
+		processed:   map[string]Result{},
+	}
+}
+
+func (f *Fake) Charge(ctx context.Context, req ChargeRequest) (Result, error) {
+	if req.Amount <= 0 {
+		return Result{}, fmt.Errorf("invalid amount %d", req.Amount)
+	}
+	if req.Method != MethodCard && req.Method != MethodBank {
+		return Result{}, fmt.Errorf("unsupported payment method %q", req.Method)
+	}
+	if req.Source == FakeUnavailable {
+		return Result{}, ErrUnavailable
+	}
+	return f.once(req.IdempotencyKey, func() Result {
+		reference := fmt.Sprintf("fake_%s_%d_%s", req.Method, f.Now().UnixNano(), req.Source)
+		result, _ := f.decide(reference)
+		return result
+	}), nil
+}
+
+func (f *Fake) Status(ctx context.Context, reference string) (Result, error) {
+	return f.decide(reference)
+}
+
+func (f *Fake) Refund(ctx context.Context, reference string, amount int64, idempotencyKey string) (Result, error) {
+	charge, err := f.decide(reference)
+	if err != nil {
+		return Result{}, err
+	}
+	if amount <= 0 {
+		return Result{}, fmt.Errorf("invalid amount %d", amount)
+	}
+	return f.once(idempotencyKey, func() Result {
+		result := Result{Reference: fmt.Sprintf("fake_refund_%d", f.Now().UnixNano()), Status: StatusSucceeded}
+		if charge.Status != StatusSucceeded {
+			result.Status = StatusFailed
+			result.FailureReason = "charge has not succeeded"
+		}
+		return result
+	}), nil
+}
+
+// once returns the result of an earlier request with the same idempotency key instead of
+// running it again
+func (f *Fake) once(idempotencyKey string, run func() Result) Result {
+	if idempotencyKey == "" {
+		return run()
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if result, ok := f.processed[idempotencyKey]; ok {
+		return result
+	}
+	result := run()
+	f.processed[idempotencyKey] = result
+	return result
+}
+
+// decide derives the state of a charge from its reference
+func (f *Fake) decide(reference string) (Result, error) {
+	parts := strings.SplitN(reference, "_", 4)
+	if len(parts) != 4 || parts[0] != "fake" {
+		return Result{}, ErrNotFound
+	}
+	createdNanos, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return Result{}, ErrNotFound
+	}
+	method, source := parts[1], parts[3]
+
+	result := Result{Reference: reference, Status: StatusSucceeded}
+	switch method {
+	case MethodCard:
+		switch source {
+		case FakeCardDeclined:
+			result.Status, result.FailureReason = StatusFailed, "card declined"
+		case FakeCardInsufficientFunds:
+			result.Status, result.FailureReason = StatusFailed, "insufficient funds"
+		}
+	case MethodBank:
+		switch {
+		case f.Now().Before(time.Unix(0, createdNanos).Add(f.SettleAfter)):
+			result.Status = StatusPending
+		case source == FakeBankInsufficientFunds:
+			result.Status, result.FailureReason = StatusFailed, "insufficient funds"
+		}
+	default:
+		return Result{}, ErrNotFound
+	}
+	return result, nil
+}
@@ -0,0 +1,74 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFakeCardPayments(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake()
+	tests := []struct {
+		source string
+		status string
+	}{
+		{"tok_visa", StatusSucceeded},
+		{FakeCardDeclined, StatusFailed},
+		{FakeCardInsufficientFunds, StatusFailed},
+	}
+	for _, tt := range tests {
+		result, err := fake.Charge(ctx, ChargeRequest{Amount: 1000, Method: MethodCard, Source: tt.source})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.source, err)
+		}
+		if result.Status != tt.status {
+			t.Fatalf("%s: got status %s, want %s", tt.source, result.Status, tt.status)
+		}
+	}
+
+	if _, err := fake.Charge(ctx, ChargeRequest{Amount: 1000, Method: MethodCard, Source: FakeUnavailable}); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("got %v, want ErrUnavailable", err)
+	}
+}
+
+func TestFakeBankTransfersSettleLater(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	fake := NewFake()
+	fake.Now = func() time.Time { return now }
+
+	ok, _ := fake.Charge(ctx, ChargeRequest{Amount: 1000, Method: MethodBank, Source: "iban"})
+	poor, _ := fake.Charge(ctx, ChargeRequest{Amount: 1000, Method: MethodBank, Source: FakeBankInsufficientFunds})
+	if ok.Status != StatusPending || poor.Status != StatusPending {
+		t.Fatalf("bank transfers should start pending, got %s and %s", ok.Status, poor.Status)
+	}
+
+	now = now.Add(fake.SettleAfter)
+	if result, _ := fake.Status(ctx, ok.Reference); result.Status != StatusSucceeded {
+		t.Fatalf("got status %s, want succeeded", result.Status)
+	}
+	if result, _ := fake.Status(ctx, poor.Reference); result.Status != StatusFailed {
+		t.Fatalf("got status %s, want failed", result.Status)
+	}
+}
+
+func TestFakeIdempotencyKey(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake()
+	req := ChargeRequest{Amount: 1000, Method: MethodCard, Source: "tok_visa", IdempotencyKey: "payment-1"}
+	first, _ := fake.Charge(ctx, req)
+	second, _ := fake.Charge(ctx, req)
+	if first.Reference != second.Reference {
+		t.Fatalf("retried charge got reference %s, want %s", second.Reference, first.Reference)
+	}
+
+	refund, err := fake.Refund(ctx, first.Reference, 500, "refund-1")
+	if err != nil || refund.Status != StatusSucceeded {
+		t.Fatalf("refund: %+v, %v", refund, err)
+	}
+	if _, err := fake.Refund(ctx, "unknown", 500, ""); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,74 @@
+// Package payment collects and refunds rent through a payment provider chosen through the
+// configuration.
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"golang-test/config"
+)
+
+// Payment methods a provider can charge
+const (
+	MethodCard = "card"
+	MethodBank = "bank"
+)
+
+// Result states. Card payments succeed or fail right away, bank transfers stay pending
+// until the bank settles them.
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+var (
+	// ErrNotFound is returned for references the provider doesn't know
+	ErrNotFound = errors.New("payment not found")
+	// ErrUnavailable is returned when the provider can't be reached, the request can be
+	// retried with the same idempotency key
+	ErrUnavailable = errors.New("payment provider unavailable")
+)
+
+// PaymentProvider charges and refunds payments. Declined payments are not errors, they
+// come back with StatusFailed and the reason.
+type PaymentProvider interface {
+	// Charge collects the amount from the source, a card token or a bank account
+	Charge(ctx context.Context, req ChargeRequest) (Result, error)
+	// Status returns the current state of a charge, e.g. to see whether a bank transfer
+	// has settled
+	Status(ctx context.Context, reference string) (Result, error)
+	// Refund pays back part or all of a charge
+	Refund(ctx context.Context, reference string, amount int64, idempotencyKey string) (Result, error)
+}
+
+// ChargeRequest describes a payment, amounts are in cents
+type ChargeRequest struct {
+	Amount      int64
+	Currency    string
+	Method      string
+	Source      string
+	Description string
+	// IdempotencyKey makes retries of the same request charge only once
+	IdempotencyKey string
+}
+
+// Result is the outcome of a charge or refund
+type Result struct {
+	Reference     string
+	Status        string
+	FailureReason string
+}
+
+// New creates the provider selected by PAYMENT_DRIVER. Only "fake" (the default) exists
+// so far, it simulates card and bank payments without reaching the network.
+func New(cfg config.Config) (PaymentProvider, error) {
+	switch cfg.PaymentDriver {
+	case "", "fake":
+		return NewFake(), nil
+	default:
+		return nil, fmt.Errorf("unknown payment driver %q", cfg.PaymentDriver)
+	}
+}
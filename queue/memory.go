@@ -0,0 +1,89 @@
+package queue
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Memory keeps jobs in process memory, it is meant for tests and for running the workers
+// inside the API process
+type Memory struct {
+	mu      sync.Mutex
+	jobs    []Job // Ordered by RunAt
+	dead    []Job
+	changed chan struct{} // Closed and replaced whenever a job is added
+}
+
+func NewMemory() *Memory {
+	return &Memory{changed: make(chan struct{})}
+}
+
+func (m *Memory) Enqueue(ctx context.Context, job Job) error {
+	m.mu.Lock()
+	i := sort.Search(len(m.jobs), func(i int) bool { return m.jobs[i].RunAt.After(job.RunAt) })
+	m.jobs = append(m.jobs, Job{})
+	copy(m.jobs[i+1:], m.jobs[i:])
+	m.jobs[i] = job
+
+	// Wake up every waiting Dequeue, they recompute how long to wait
+	close(m.changed)
+	m.changed = make(chan struct{})
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *Memory) Dequeue(ctx context.Context) (Job, error) {
+	for {
+		wait := time.Hour
+		m.mu.Lock()
+		if len(m.jobs) > 0 {
+			wait = time.Until(m.jobs[0].RunAt)
+			if wait <= 0 {
+				job := m.jobs[0]
+				m.jobs = m.jobs[1:]
+				m.mu.Unlock()
+				return job, nil
+			}
+		}
+		changed := m.changed
+		m.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return Job{}, ctx.Err()
+		case <-changed:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// Ack does nothing, the jobs of a process that stops are lost anyway
+func (m *Memory) Ack(ctx context.Context, job Job) error {
+	return nil
+}
+
+func (m *Memory) Bury(ctx context.Context, job Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dead = append(m.dead, job)
+	return nil
+}
+
+// Pending returns the number of jobs waiting to run
+func (m *Memory) Pending() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.jobs)
+}
+
+// Dead returns the buried jobs
+func (m *Memory) Dead() []Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Job(nil), m.dead...)
+}
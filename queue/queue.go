@@ -0,0 +1,103 @@
+// Package queue runs slow side effects as background jobs, with retries and a dead-letter
+// list for jobs that keep failing.
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"golang-test/config"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// DefaultMaxAttempts is how often a job runs before it is moved to the dead-letter list
+const DefaultMaxAttempts = 5
+
+// Job is a unit of work, Payload holds the JSON encoded arguments of its type
+type Job struct {
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	Payload     json.RawMessage `json:"payload"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"maxAttempts"`
+	RunAt       time.Time       `json:"runAt"`
+	LastError   string          `json:"lastError,omitempty"`
+	EnqueuedAt  time.Time       `json:"enqueuedAt"`
+
+	// raw is the job as the driver stored it, to acknowledge it
+	raw string
+}
+
+// Queue is the job store shared by the API and the workers
+type Queue interface {
+	// Enqueue adds the job, it becomes available once RunAt has passed
+	Enqueue(ctx context.Context, job Job) error
+	// Dequeue blocks until a job is available or the context is done. The job stays
+	// reserved until it is acknowledged.
+	Dequeue(ctx context.Context) (Job, error)
+	// Ack releases a dequeued job once it ran, was rescheduled or buried. Drivers that
+	// outlive the worker hand a job that isn't acknowledged in time to another worker.
+	Ack(ctx context.Context, job Job) error
+	// Bury moves a job that won't be retried to the dead-letter list
+	Bury(ctx context.Context, job Job) error
+}
+
+// Option changes a job before it is enqueued
+type Option func(*Job)
+
+// RunAt delays the job until t
+func RunAt(t time.Time) Option {
+	return func(job *Job) { job.RunAt = t }
+}
+
+// MaxAttempts overrides DefaultMaxAttempts
+func MaxAttempts(n int) Option {
+	return func(job *Job) { job.MaxAttempts = n }
+}
+
+// Enqueue adds a job of the given type with the payload encoded as JSON
+func Enqueue(ctx context.Context, q Queue, jobType string, payload any, options ...Option) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s job: %w", jobType, err)
+	}
+	id, err := newJobID()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	job := Job{
+		ID:          id,
+		Type:        jobType,
+		Payload:     body,
+		MaxAttempts: DefaultMaxAttempts,
+		RunAt:       now,
+		EnqueuedAt:  now,
+	}
+	for _, option := range options {
+		option(&job)
+	}
+	return q.Enqueue(ctx, job)
+}
+
+// New creates the driver selected by QUEUE_DRIVER: "redis" (the default) or "memory",
+// which only works when the jobs are handled by the same process. Without a Redis
+// client the redis driver falls back to the memory driver.
+func New(cfg config.Config, client *redis.Client) (Queue, error) {
+	switch cfg.QueueDriver {
+	case "", "redis":
+		if client == nil {
+			log.Println("Warning: Redis unavailable, running background jobs in process")
+			return NewMemory(), nil
+		}
+		return NewRedis(client, "jobs"), nil
+	case "memory":
+		return NewMemory(), nil
+	default:
+		return nil, fmt.Errorf("unknown queue driver %q", cfg.QueueDriver)
+	}
+}
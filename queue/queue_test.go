@@ -0,0 +1,146 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang-test/config"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+type greeting struct {
+	Name string `json:"name"`
+}
+
+func runWorker(t *testing.T, worker *Worker, until func() bool) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		worker.Run(ctx)
+		close(done)
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for !until() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+}
+
+func TestWorkerDecodesPayload(t *testing.T) {
+	q := NewMemory()
+	worker := NewWorker(q, 2)
+	var got atomic.Value
+	Handle(worker, "greet", func(ctx context.Context, payload greeting) error {
+		got.Store(payload.Name)
+		return nil
+	})
+
+	if err := Enqueue(context.Background(), q, "greet", greeting{Name: "ada"}); err != nil {
+		t.Fatal(err)
+	}
+	runWorker(t, worker, func() bool { return got.Load() != nil })
+
+	if got.Load() != "ada" {
+		t.Fatalf("handler got %v, want ada", got.Load())
+	}
+}
+
+func TestWorkerRetriesThenBuries(t *testing.T) {
+	q := NewMemory()
+	worker := NewWorker(q, 1)
+	worker.BaseDelay = time.Millisecond
+	var calls atomic.Int32
+	worker.Handle("fail", func(ctx context.Context, payload json.RawMessage) error {
+		calls.Add(1)
+		return errors.New("boom")
+	})
+
+	if err := Enqueue(context.Background(), q, "fail", nil, MaxAttempts(3)); err != nil {
+		t.Fatal(err)
+	}
+	runWorker(t, worker, func() bool { return len(q.Dead()) > 0 })
+
+	if calls.Load() != 3 {
+		t.Fatalf("handler ran %d times, want 3", calls.Load())
+	}
+	dead := q.Dead()
+	if len(dead) != 1 || dead[0].Attempts != 3 || dead[0].LastError != "boom" {
+		t.Fatalf("unexpected dead-letter list %+v", dead)
+	}
+	if q.Pending() != 0 {
+		t.Fatalf("%d jobs still pending", q.Pending())
+	}
+}
+
+func TestWorkerBuriesUnknownJobTypes(t *testing.T) {
+	q := NewMemory()
+	worker := NewWorker(q, 1)
+
+	if err := Enqueue(context.Background(), q, "unknown", nil); err != nil {
+		t.Fatal(err)
+	}
+	runWorker(t, worker, func() bool { return len(q.Dead()) > 0 })
+
+	if dead := q.Dead(); len(dead) != 1 || dead[0].Attempts != 1 {
+		t.Fatalf("unexpected dead-letter list %+v", dead)
+	}
+}
+
+func TestMemoryWaitsForRunAt(t *testing.T) {
+	q := NewMemory()
+	runAt := time.Now().Add(50 * time.Millisecond)
+	if err := Enqueue(context.Background(), q, "later", nil, RunAt(runAt)); err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := q.Dequeue(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Now().Before(runAt) {
+		t.Fatalf("job %s ran before %s", job.ID, runAt)
+	}
+}
+
+func TestWorkerRunsPeriodicTasks(t *testing.T) {
+	worker := NewWorker(NewMemory(), 1)
+	var runs atomic.Int32
+	worker.Every(5*time.Millisecond, "count", func(ctx context.Context) error {
+		runs.Add(1)
+		return errors.New("failed runs are tried again")
+	})
+	runWorker(t, worker, func() bool { return runs.Load() >= 3 })
+
+	if runs.Load() < 3 {
+		t.Fatalf("task ran %d times, want at least 3", runs.Load())
+	}
+}
+
+func TestNewFallsBackWithoutRedis(t *testing.T) {
+	for _, driver := range []string{"", "redis", "memory"} {
+		q, err := New(config.Config{QueueDriver: driver}, nil)
+		if err != nil {
+			t.Fatalf("%q: %v", driver, err)
+		}
+		if _, ok := q.(*Memory); !ok {
+			t.Errorf("%q: got %T without a redis client, want the memory driver", driver, q)
+		}
+	}
+
+	server := miniredis.RunT(t)
+	q, err := New(config.Config{}, redis.NewClient(&redis.Options{Addr: server.Addr()}))
+	if _, ok := q.(*Redis); err != nil || !ok {
+		t.Fatalf("got %T, %v with a redis client, want the redis driver", q, err)
+	}
+	if _, err := New(config.Config{QueueDriver: "kafka"}, nil); err == nil {
+		t.Fatal("an unknown driver was accepted")
+	}
+}
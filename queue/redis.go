@@ -0,0 +1,178 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// deadLetterLimit caps the dead-letter list, the oldest jobs are dropped first
+const deadLetterLimit = 10000
+
+// promoteScript moves jobs that are due from the scheduled set to the ready list. Doing
+// it in a script keeps two workers from promoting the same job.
+var promoteScript = redis.NewScript(`
+local jobs = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, 100)
+for _, job in ipairs(jobs) do
+	redis.call('ZREM', KEYS[1], job)
+	redis.call('LPUSH', KEYS[2], job)
+end
+return #jobs
+`)
+
+// requeueScript puts jobs that were taken longer than the visibility timeout ago back on
+// the ready list. A job without a start time was taken by a worker that stopped before
+// recording it, it gets the full timeout from now.
+var requeueScript = redis.NewScript(`
+local jobs = redis.call('LRANGE', KEYS[1], 0, -1)
+local requeued = 0
+for _, job in ipairs(jobs) do
+	local started = redis.call('HGET', KEYS[2], job)
+	if not started then
+		redis.call('HSET', KEYS[2], job, ARGV[1])
+	elseif tonumber(started) <= tonumber(ARGV[2]) then
+		redis.call('LREM', KEYS[1], 1, job)
+		redis.call('HDEL', KEYS[2], job)
+		redis.call('RPUSH', KEYS[3], job)
+		requeued = requeued + 1
+	end
+end
+return requeued
+`)
+
+// Redis keeps ready jobs in a list, delayed jobs in a sorted set scored by their run
+// time and buried jobs in a dead-letter list. Dequeue moves a job to a processing list
+// until it is acknowledged, a job whose worker was killed goes back to the ready list
+// after the visibility timeout. Handlers may therefore run more than once.
+type Redis struct {
+	client        *redis.Client
+	readyKey      string
+	scheduledKey  string
+	processingKey string
+	startedKey    string
+	deadKey       string
+	pollInterval  time.Duration
+	// VisibilityTimeout is how long a job may run before it is handed out again
+	VisibilityTimeout time.Duration
+	requeueInterval   time.Duration
+
+	mu          sync.Mutex
+	lastRequeue time.Time
+}
+
+// NewRedis creates a queue whose keys start with prefix
+func NewRedis(client *redis.Client, prefix string) *Redis {
+	return &Redis{
+		client:            client,
+		readyKey:          prefix + ":ready",
+		scheduledKey:      prefix + ":scheduled",
+		processingKey:     prefix + ":processing",
+		startedKey:        prefix + ":started",
+		deadKey:           prefix + ":dead",
+		pollInterval:      time.Second,
+		VisibilityTimeout: 15 * time.Minute,
+		requeueInterval:   30 * time.Second,
+	}
+}
+
+func (r *Redis) Enqueue(ctx context.Context, job Job) error {
+	body, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	if job.RunAt.After(time.Now()) {
+		return r.client.ZAdd(ctx, r.scheduledKey, &redis.Z{Score: float64(job.RunAt.UnixMilli()), Member: body}).Err()
+	}
+	return r.client.LPush(ctx, r.readyKey, body).Err()
+}
+
+func (r *Redis) Dequeue(ctx context.Context) (Job, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return Job{}, err
+		}
+		now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+		if err := promoteScript.Run(ctx, r.client, []string{r.scheduledKey, r.readyKey}, now).Err(); err != nil && !errors.Is(err, redis.Nil) {
+			return Job{}, err
+		}
+		if err := r.requeueStale(ctx); err != nil {
+			return Job{}, err
+		}
+
+		// Wake up regularly so scheduled jobs are promoted while the ready list is empty
+		body, err := r.client.BRPopLPush(ctx, r.readyKey, r.processingKey, r.pollInterval).Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return Job{}, err
+		}
+		if err := r.client.HSet(ctx, r.startedKey, body, time.Now().UnixMilli()).Err(); err != nil {
+			return Job{}, err
+		}
+
+		var job Job
+		if err := json.Unmarshal([]byte(body), &job); err != nil {
+			// A job that can't be decoded would fail forever, keep it for inspection
+			r.client.LPush(ctx, r.deadKey, body)
+			r.Ack(ctx, Job{raw: body})
+			return Job{}, fmt.Errorf("failed to decode job: %w", err)
+		}
+		job.raw = body
+		return job, nil
+	}
+}
+
+func (r *Redis) Ack(ctx context.Context, job Job) error {
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LRem(ctx, r.processingKey, 1, job.raw)
+		pipe.HDel(ctx, r.startedKey, job.raw)
+		return nil
+	})
+	return err
+}
+
+// requeueStale hands out jobs again whose worker didn't acknowledge them within the
+// visibility timeout. It runs at most once per requeueInterval.
+func (r *Redis) requeueStale(ctx context.Context) error {
+	r.mu.Lock()
+	now := time.Now()
+	due := now.Sub(r.lastRequeue) >= r.requeueInterval
+	if due {
+		r.lastRequeue = now
+	}
+	r.mu.Unlock()
+	if !due {
+		return nil
+	}
+
+	keys := []string{r.processingKey, r.startedKey, r.readyKey}
+	requeued, err := requeueScript.Run(ctx, r.client, keys, now.UnixMilli(), now.Add(-r.VisibilityTimeout).UnixMilli()).Int()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return err
+	}
+	if requeued > 0 {
+		log.Printf("Requeued %d jobs that weren't acknowledged in time", requeued)
+	}
+	return nil
+}
+
+func (r *Redis) Bury(ctx context.Context, job Job) error {
+	body, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(ctx, r.deadKey, body)
+		pipe.LTrim(ctx, r.deadKey, 0, deadLetterLimit-1)
+		return nil
+	})
+	return err
+}
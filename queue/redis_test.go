@@ -0,0 +1,94 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func newTestRedis(t *testing.T) *Redis {
+	t.Helper()
+	server := miniredis.RunT(t)
+	q := NewRedis(redis.NewClient(&redis.Options{Addr: server.Addr()}), "jobs")
+	q.pollInterval = 10 * time.Millisecond
+	return q
+}
+
+func TestRedisAckRemovesJob(t *testing.T) {
+	ctx := context.Background()
+	q := newTestRedis(t)
+	if err := Enqueue(ctx, q, "greet", greeting{Name: "ada"}); err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := q.Dequeue(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := q.client.LLen(ctx, q.processingKey).Val(); n != 1 {
+		t.Fatalf("%d jobs processing, want 1", n)
+	}
+	if err := q.Ack(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	if n := q.client.LLen(ctx, q.processingKey).Val(); n != 0 {
+		t.Fatalf("%d jobs processing after ack, want 0", n)
+	}
+	if n := q.client.HLen(ctx, q.startedKey).Val(); n != 0 {
+		t.Fatalf("%d start times left after ack, want 0", n)
+	}
+}
+
+func TestRedisRequeuesUnacknowledgedJobs(t *testing.T) {
+	ctx := context.Background()
+	q := newTestRedis(t)
+	q.VisibilityTimeout = 20 * time.Millisecond
+	q.requeueInterval = 0
+	if err := Enqueue(ctx, q, "greet", greeting{Name: "ada"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first worker dies without acknowledging the job
+	first, err := q.Dequeue(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * q.VisibilityTimeout)
+
+	dequeueCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	second, err := q.Dequeue(dequeueCtx)
+	if err != nil {
+		t.Fatalf("job wasn't handed out again: %v", err)
+	}
+	if second.ID != first.ID {
+		t.Fatalf("got job %s, want %s", second.ID, first.ID)
+	}
+	if n := q.client.LLen(ctx, q.processingKey).Val(); n != 1 {
+		t.Fatalf("%d jobs processing, want 1", n)
+	}
+}
+
+func TestWorkerAcknowledgesRedisJobs(t *testing.T) {
+	ctx := context.Background()
+	q := newTestRedis(t)
+	worker := NewWorker(q, 1)
+	done := make(chan struct{}, 1)
+	Handle(worker, "greet", func(ctx context.Context, payload greeting) error {
+		done <- struct{}{}
+		return nil
+	})
+	if err := Enqueue(ctx, q, "greet", greeting{Name: "ada"}); err != nil {
+		t.Fatal(err)
+	}
+	runWorker(t, worker, func() bool {
+		return len(done) > 0 && q.client.LLen(ctx, q.processingKey).Val() == 0
+	})
+
+	if n := q.client.LLen(ctx, q.processingKey).Val(); n != 0 {
+		t.Fatalf("%d jobs left processing, want 0", n)
+	}
+}
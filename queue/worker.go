@@ -0,0 +1,193 @@
+package queue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	mathrand "math/rand"
+	"sync"
+	"time"
+)
+
+// HandlerFunc handles the JSON encoded payload of a job
+type HandlerFunc func(ctx context.Context, payload json.RawMessage) error
+
+// Worker takes jobs off the queue and runs the handler registered for their type. Failed
+// jobs are retried with exponential backoff until they run out of attempts.
+type Worker struct {
+	Queue       Queue
+	Concurrency int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+
+	handlers map[string]HandlerFunc
+	tasks    []periodicTask
+}
+
+// periodicTask runs at an interval for as long as the worker runs
+type periodicTask struct {
+	name     string
+	interval time.Duration
+	run      func(ctx context.Context) error
+}
+
+func NewWorker(q Queue, concurrency int) *Worker {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &Worker{
+		Queue:       q,
+		Concurrency: concurrency,
+		BaseDelay:   time.Second,
+		MaxDelay:    10 * time.Minute,
+		handlers:    map[string]HandlerFunc{},
+	}
+}
+
+// Handle registers the handler for a job type
+func (w *Worker) Handle(jobType string, handler HandlerFunc) {
+	w.handlers[jobType] = handler
+}
+
+// Handle registers a handler that gets the payload decoded into T
+func Handle[T any](w *Worker, jobType string, handler func(ctx context.Context, payload T) error) {
+	w.Handle(jobType, func(ctx context.Context, body json.RawMessage) error {
+		var payload T
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return fmt.Errorf("failed to decode payload: %w", err)
+		}
+		return handler(ctx, payload)
+	})
+}
+
+// Every runs the task right away and then at the interval while the worker runs. Tasks
+// suit work that must not depend on a single job, like sweeping up due work whose job
+// was lost. Every worker process runs them, so they have to be safe to run concurrently.
+func (w *Worker) Every(interval time.Duration, name string, task func(ctx context.Context) error) {
+	w.tasks = append(w.tasks, periodicTask{name: name, interval: interval, run: task})
+}
+
+// Run handles jobs until the context is cancelled, jobs that are running are finished first
+func (w *Worker) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, task := range w.tasks {
+		wg.Add(1)
+		go func(task periodicTask) {
+			defer wg.Done()
+			w.runPeriodically(ctx, task)
+		}(task)
+	}
+	for i := 0; i < w.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				job, err := w.Queue.Dequeue(ctx)
+				if ctx.Err() != nil {
+					return
+				}
+				if err != nil {
+					log.Printf("Failed to dequeue job: %v", err)
+					time.Sleep(time.Second)
+					continue
+				}
+				// Jobs aren't cut short by a shutdown, they may not be safe to interrupt
+				w.process(context.WithoutCancel(ctx), job)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// runPeriodically runs the task until the context is cancelled, a failed run is logged
+// and tried again at the next tick
+func (w *Worker) runPeriodically(ctx context.Context, task periodicTask) {
+	ticker := time.NewTicker(task.interval)
+	defer ticker.Stop()
+	for {
+		if err := task.run(context.WithoutCancel(ctx)); err != nil {
+			log.Printf("Periodic task %q failed: %v", task.name, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// process runs the job and acknowledges it once it ran, was rescheduled or buried. A job
+// that couldn't be rescheduled or buried isn't acknowledged, so the queue hands it out
+// again.
+func (w *Worker) process(ctx context.Context, job Job) {
+	job.Attempts++
+	err := w.run(ctx, job)
+	if err == nil {
+		w.ack(ctx, job)
+		return
+	}
+	job.LastError = err.Error()
+
+	if errors.Is(err, errUnknownJobType) || job.Attempts >= job.MaxAttempts {
+		log.Printf("Job %s (%s) failed for good after %d attempts: %v", job.ID, job.Type, job.Attempts, err)
+		if err := w.Queue.Bury(ctx, job); err != nil {
+			log.Printf("Failed to bury job %s: %v", job.ID, err)
+			return
+		}
+		w.ack(ctx, job)
+		return
+	}
+
+	job.RunAt = time.Now().Add(w.backoff(job.Attempts))
+	log.Printf("Job %s (%s) failed, retrying at %s: %v", job.ID, job.Type, job.RunAt.Format(time.RFC3339), err)
+	if err := w.Queue.Enqueue(ctx, job); err != nil {
+		log.Printf("Failed to reschedule job %s: %v", job.ID, err)
+		return
+	}
+	w.ack(ctx, job)
+}
+
+func (w *Worker) ack(ctx context.Context, job Job) {
+	if err := w.Queue.Ack(ctx, job); err != nil {
+		log.Printf("Failed to acknowledge job %s: %v", job.ID, err)
+	}
+}
+
+var errUnknownJobType = errors.New("unknown job type")
+
+// run calls the handler, turning a panic into an error so it doesn't take the worker down
+func (w *Worker) run(ctx context.Context, job Job) (err error) {
+	handler, ok := w.handlers[job.Type]
+	if !ok {
+		return fmt.Errorf("%w %q", errUnknownJobType, job.Type)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handler(ctx, job.Payload)
+}
+
+// backoff doubles the delay with every attempt and adds up to 20% jitter so retries of
+// jobs that failed together are spread out
+func (w *Worker) backoff(attempts int) time.Duration {
+	delay := float64(w.BaseDelay) * math.Pow(2, float64(attempts-1))
+	if delay > float64(w.MaxDelay) {
+		delay = float64(w.MaxDelay)
+	}
+	return time.Duration(delay * (1 + 0.2*mathrand.Float64()))
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate job id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// Retry wraps a driver and retries operations that failed with a transient error,
+// waiting with exponential backoff and jitter between attempts
+type Retry struct {
+	Storage
+	attempts int
+	delay    time.Duration
+}
+
+// WithRetry makes up to attempts tries per operation starting with the given delay
+func WithRetry(s Storage, attempts int, delay time.Duration) *Retry {
+	if attempts < 1 {
+		attempts = 1
+	}
+	return &Retry{Storage: s, attempts: attempts, delay: delay}
+}
+
+func (r *Retry) Put(ctx context.Context, key string, body []byte, contentType string) error {
+	return r.do(ctx, func() error {
+		return r.Storage.Put(ctx, key, body, contentType)
+	})
+}
+
+func (r *Retry) Get(ctx context.Context, key string) ([]byte, error) {
+	var body []byte
+	err := r.do(ctx, func() error {
+		var err error
+		body, err = r.Storage.Get(ctx, key)
+		return err
+	})
+	return body, err
+}
+
+func (r *Retry) Delete(ctx context.Context, key string) error {
+	return r.do(ctx, func() error {
+		return r.Storage.Delete(ctx, key)
+	})
+}
+
+func (r *Retry) Exists(ctx context.Context, key string) (bool, error) {
+	var exists bool
+	err := r.do(ctx, func() error {
+		var err error
+		exists, err = r.Storage.Exists(ctx, key)
+		return err
+	})
+	return exists, err
+}
+
+func (r *Retry) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	var info ObjectInfo
+	err := r.do(ctx, func() error {
+		var err error
+		info, err = r.Storage.Stat(ctx, key)
+		return err
+	})
+	return info, err
+}
+
+// Unwrap returns the wrapped driver
+func (r *Retry) Unwrap() Storage {
+	return r.Storage
+}
+
+func (r *Retry) do(ctx context.Context, operation func() error) error {
+	delay := r.delay
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = operation()
+		if err == nil || attempt >= r.attempts || !isTransient(err) {
+			return err
+		}
+
+		// Sleep for the delay plus up to 50% jitter so concurrent retries spread out
+		wait := delay + time.Duration(rand.Int63n(int64(delay)/2+1))
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		delay *= 2
+	}
+}
+
+// throttlingCodes are client errors that are worth retrying
+var throttlingCodes = map[string]bool{
+	"SlowDown":                 true,
+	"Throttling":               true,
+	"ThrottlingException":      true,
+	"RequestTimeout":           true,
+	"RequestTimeTooSkewed":     true,
+	"TooManyRequestsException": true,
+}
+
+// isTransient reports whether retrying the operation could succeed: network failures,
+// faults of the storage service and throttling. Anything else, such as a full or read-only
+// disk, fails the same way again.
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var responseErr *smithyhttp.ResponseError
+	if errors.As(err, &responseErr) {
+		status := responseErr.HTTPStatusCode()
+		if status == http.StatusTooManyRequests || status >= http.StatusInternalServerError {
+			return true
+		}
+	}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrorFault() == smithy.FaultServer || throttlingCodes[apiErr.ErrorCode()]
+	}
+	// Not net.Error, system errors such as ENOSPC implement it as well
+	var sendErr *smithyhttp.RequestSendError
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	return errors.As(err, &sendErr) || errors.As(err, &opErr) || errors.As(err, &dnsErr) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
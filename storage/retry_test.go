@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang-test/config"
+
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// flaky fails the first calls to Put with err before passing them to the memory driver
+type flaky struct {
+	*Memory
+	failures int
+	err      error
+	calls    int
+}
+
+func (f *flaky) Put(ctx context.Context, key string, body []byte, contentType string) error {
+	f.calls++
+	if f.calls <= f.failures {
+		return f.err
+	}
+	return f.Memory.Put(ctx, key, body, contentType)
+}
+
+func responseError(status int) error {
+	return &smithyhttp.ResponseError{
+		Response: &smithyhttp.Response{Response: &http.Response{StatusCode: status}},
+		Err:      errors.New("request failed"),
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"connection refused", &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, true},
+		{"request not sent", &smithyhttp.RequestSendError{Err: errors.New("connection reset")}, true},
+		{"service unavailable", responseError(http.StatusServiceUnavailable), true},
+		{"too many requests", responseError(http.StatusTooManyRequests), true},
+		{"server fault", &smithy.GenericAPIError{Code: "InternalError", Fault: smithy.FaultServer}, true},
+		{"slow down", &smithy.GenericAPIError{Code: "SlowDown", Fault: smithy.FaultClient}, true},
+		{"access denied", &smithy.GenericAPIError{Code: "AccessDenied", Fault: smithy.FaultClient}, false},
+		{"forbidden", responseError(http.StatusForbidden), false},
+		{"permission denied", &fs.PathError{Op: "open", Path: "storage/a", Err: syscall.EACCES}, false},
+		{"disk full", &fs.PathError{Op: "write", Path: "storage/a", Err: syscall.ENOSPC}, false},
+		{"not found", ErrNotFound, false},
+		{"invalid key", ErrInvalidKey, false},
+		{"canceled", context.Canceled, false},
+		{"unknown", errors.New("something else"), false},
+	}
+	for _, tt := range tests {
+		if got := isTransient(tt.err); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRetryAttempts(t *testing.T) {
+	transient := &smithy.GenericAPIError{Code: "SlowDown", Fault: smithy.FaultClient}
+	tests := []struct {
+		name      string
+		failures  int
+		err       error
+		wantCalls int
+		wantErr   bool
+	}{
+		{"succeeds at once", 0, transient, 1, false},
+		{"succeeds after retries", 2, transient, 3, false},
+		{"gives up", 5, transient, 3, true},
+		{"doesn't retry local errors", 5, &fs.PathError{Op: "write", Path: "a", Err: syscall.ENOSPC}, 1, true},
+	}
+	for _, tt := range tests {
+		store := &flaky{Memory: NewMemory(), failures: tt.failures, err: tt.err}
+		err := WithRetry(store, 3, time.Millisecond).Put(context.Background(), "a", []byte("a"), "text/plain")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: got error %v", tt.name, err)
+		}
+		if store.calls != tt.wantCalls {
+			t.Errorf("%s: got %d calls, want %d", tt.name, store.calls, tt.wantCalls)
+		}
+	}
+}
+
+func TestRetryBacksOff(t *testing.T) {
+	store := &flaky{Memory: NewMemory(), failures: 3, err: responseError(http.StatusServiceUnavailable)}
+	start := time.Now()
+	if err := WithRetry(store, 4, 10*time.Millisecond).Put(context.Background(), "a", nil, ""); err != nil {
+		t.Fatal(err)
+	}
+	// Waits of 10, 20 and 40ms, each with up to 50% jitter
+	if elapsed := time.Since(start); elapsed < 70*time.Millisecond || elapsed > time.Second {
+		t.Fatalf("retrying took %s, want about 70ms to 105ms", elapsed)
+	}
+}
+
+func TestRetryStopsWhenCanceled(t *testing.T) {
+	store := &flaky{Memory: NewMemory(), failures: 5, err: responseError(http.StatusServiceUnavailable)}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := WithRetry(store, 5, time.Second).Put(ctx, "a", nil, "")
+	if err == nil || store.calls != 1 {
+		t.Fatalf("got %v after %d calls, want the first error", err, store.calls)
+	}
+}
+
+func TestNewRetriesOnlyS3(t *testing.T) {
+	store, err := New(config.Config{StorageDriver: "local", StorageLocalPath: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.(*Retry); ok {
+		t.Fatal("the local driver shouldn't be retried")
+	}
+}
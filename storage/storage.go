@@ -0,0 +1,94 @@
+// Package storage stores uploaded files behind a driver chosen through the configuration.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"golang-test/config"
+)
+
+var (
+	// ErrNotFound is returned when an object doesn't exist
+	ErrNotFound = errors.New("object not found")
+	// ErrInvalidKey is returned for keys that would escape the storage root
+	ErrInvalidKey = errors.New("invalid object key")
+)
+
+// Storage is an object store addressed by slash separated keys
+type Storage interface {
+	// Put stores the object, replacing any object with the same key
+	Put(ctx context.Context, key string, body []byte, contentType string) error
+	// Get returns the object's content, or ErrNotFound
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Delete removes the object, deleting a missing object is not an error
+	Delete(ctx context.Context, key string) error
+	// Exists reports whether the object exists
+	Exists(ctx context.Context, key string) (bool, error)
+	// Stat returns the object's size and content type, or ErrNotFound
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+}
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Size        int64
+	ContentType string
+}
+
+// Presigner is implemented by drivers that can hand out time limited URLs, so clients
+// can transfer objects directly without going through the API
+type Presigner interface {
+	// PresignPut returns a URL for uploading the object with a PUT request and the
+	// headers the request has to carry
+	PresignPut(ctx context.Context, key, contentType string, size int64, expires time.Duration) (string, http.Header, error)
+	// PresignGet returns a URL for downloading the object
+	PresignGet(ctx context.Context, key string, expires time.Duration) (string, error)
+}
+
+// PresignerOf returns the presigner behind s, looking through wrapping drivers
+func PresignerOf(s Storage) (Presigner, bool) {
+	for {
+		if presigner, ok := s.(Presigner); ok {
+			return presigner, true
+		}
+		wrapper, ok := s.(interface{ Unwrap() Storage })
+		if !ok {
+			return nil, false
+		}
+		s = wrapper.Unwrap()
+	}
+}
+
+// New creates the driver selected by STORAGE_DRIVER: "s3" (the default) for AWS or
+// any S3-compatible endpoint, "local" for a directory on disk, or "memory". Transient
+// failures of the s3 driver are retried STORAGE_RETRY_ATTEMPTS times.
+func New(cfg config.Config) (Storage, error) {
+	switch cfg.StorageDriver {
+	case "", "s3":
+		driver, err := NewS3(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return WithRetry(driver, cfg.StorageRetryAttempts, 200*time.Millisecond), nil
+	case "local":
+		return NewLocal(cfg.StorageLocalPath)
+	case "memory":
+		return NewMemory(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.StorageDriver)
+	}
+}
+
+// cleanKey rejects empty and absolute keys and keys that would escape the storage root
+func cleanKey(key string) (string, error) {
+	cleaned := path.Clean("/" + key)[1:]
+	if key == "" || cleaned == "" || cleaned != key || strings.Contains(key, "\\") {
+		return "", fmt.Errorf("%w %q", ErrInvalidKey, key)
+	}
+	return cleaned, nil
+}
@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCleanKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"property/property_1/image_0.jpg", "property/property_1/image_0.jpg"},
+		{"a.txt", "a.txt"},
+		{"", ""},
+		{"/", ""},
+		{"..", ""},
+		{"../secret", ""},
+		{"a/../../secret", ""},
+		{"a/../b", ""},
+		{"./a", ""},
+		{"a//b", ""},
+		{"a/", ""},
+		{"/etc/passwd", ""},
+		{"..\\secret", ""},
+	}
+	for _, tt := range tests {
+		got, err := cleanKey(tt.key)
+		if tt.want == "" {
+			if !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("cleanKey(%q) = %q, %v, want ErrInvalidKey", tt.key, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("cleanKey(%q) = %q, %v, want %q", tt.key, got, err, tt.want)
+		}
+	}
+}
+
+// testDriver runs the operations every driver has to support against s
+func testDriver(t *testing.T, s Storage) {
+	t.Helper()
+	ctx := context.Background()
+	key := "property/property_1/image_0.png"
+	body := []byte("\x89PNG\r\n\x1a\nnot really a png")
+
+	if _, err := s.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get of a missing object: got %v, want ErrNotFound", err)
+	}
+	if _, err := s.Stat(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Stat of a missing object: got %v, want ErrNotFound", err)
+	}
+	if err := s.Put(ctx, key, body, "image/png"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Get(ctx, key)
+	if err != nil || !bytes.Equal(got, body) {
+		t.Fatalf("Get: got %q, %v", got, err)
+	}
+	if exists, err := s.Exists(ctx, key); err != nil || !exists {
+		t.Fatalf("Exists: got %v, %v", exists, err)
+	}
+	info, err := s.Stat(ctx, key)
+	if err != nil || info.Size != int64(len(body)) || info.ContentType != "image/png" {
+		t.Fatalf("Stat: got %+v, %v", info, err)
+	}
+
+	// Putting the same key again replaces the object
+	if err := s.Put(ctx, key, []byte("replaced"), "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.Get(ctx, key); string(got) != "replaced" {
+		t.Fatalf("got %q after replacing the object", got)
+	}
+
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get after Delete: got %v, want ErrNotFound", err)
+	}
+	if exists, err := s.Exists(ctx, key); err != nil || exists {
+		t.Fatalf("Exists after Delete: got %v, %v", exists, err)
+	}
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatalf("deleting a missing object: %v", err)
+	}
+
+	for _, invalid := range []string{"", "../outside", "/etc/passwd"} {
+		if err := s.Put(ctx, invalid, body, "image/png"); !errors.Is(err, ErrInvalidKey) {
+			t.Fatalf("Put(%q): got %v, want ErrInvalidKey", invalid, err)
+		}
+		if _, err := s.Get(ctx, invalid); !errors.Is(err, ErrInvalidKey) {
+			t.Fatalf("Get(%q): got %v, want ErrInvalidKey", invalid, err)
+		}
+		if err := s.Delete(ctx, invalid); !errors.Is(err, ErrInvalidKey) {
+			t.Fatalf("Delete(%q): got %v, want ErrInvalidKey", invalid, err)
+		}
+	}
+}
+
+func TestMemory(t *testing.T) {
+	store := NewMemory()
+	testDriver(t, store)
+
+	ctx := context.Background()
+	store.Put(ctx, "a/1", []byte("1"), "text/plain")
+	store.Put(ctx, "a/2", []byte("2"), "text/plain")
+	store.Delete(ctx, "a/1")
+	if keys := store.Keys(); len(keys) != 1 || keys[0] != "a/2" {
+		t.Fatalf("got keys %v, want [a/2]", keys)
+	}
+
+	// The stored object doesn't share memory with the caller's slice
+	body := []byte("original")
+	store.Put(ctx, "copy", body, "text/plain")
+	body[0] = 'X'
+	if got, _ := store.Get(ctx, "copy"); string(got) != "original" {
+		t.Fatalf("got %q, the object changed with the caller's slice", got)
+	}
+}
+
+func TestLocal(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "storage")
+	store, err := NewLocal(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testDriver(t, store)
+
+	// Objects are files below the root, and no temporary files are left behind
+	ctx := context.Background()
+	if err := store.Put(ctx, "a/b.txt", []byte("hello"), "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(filepath.Join(root, "a", "b.txt"))
+	if err != nil || string(got) != "hello" {
+		t.Fatalf("got %q, %v", got, err)
+	}
+	entries, err := os.ReadDir(filepath.Join(root, "a"))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("got %d files in the directory, want 1", len(entries))
+	}
+}
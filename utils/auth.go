@@ -1,6 +1,10 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"net/http"
 	"github.com/gin-gonic/gin"
@@ -8,6 +12,26 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// IsTokenRevoked reports whether the access token with the given jti has been revoked.
+// It is replaced during server startup with a lookup against the token denylist.
+var IsTokenRevoked = func(jti string) bool {
+	return false
+}
+
+// UserHasPermission reports whether the user's role has been granted the named permission.
+// It is replaced during server startup with a lookup against the role permissions.
+var UserHasPermission = func(userID uint, permission string) bool {
+	return false
+}
+
+// tokenClaims holds the values extracted from a verified access token
+type tokenClaims struct {
+	UserID    uint
+	RoleID    uint
+	JTI       string
+	SessionID string
+}
+
 // HashPassword generates a bcrypt hash for the given password.
 func HashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
@@ -20,26 +44,37 @@ func VerifyPassword(password, hash string) bool {
 	return err == nil
 }
 
-func verifyToken(tokenString string) (uint, uint, error) {
-	var secretKey = []byte("secret-key")
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return secretKey, nil
-	})
+// GenerateRandomToken returns a URL-safe random string built from n random bytes.
+func GenerateRandomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken returns the hex encoded SHA-256 digest of an opaque token so it can be stored safely.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func verifyToken(tokenString string) (*tokenClaims, error) {
+	token, err := jwt.Parse(tokenString, verificationKey)
 
 	if err != nil {
-		return 0, 0, err
+		return nil, err
 	}
 
 	if !token.Valid {
-		return 0, 0, fmt.Errorf("invalid token")
+		return nil, fmt.Errorf("invalid token")
 	}
 	if claims, ok := token.Claims.(jwt.MapClaims); ok {
 		// Extract user ID
 		userIdFloat, ok := claims["userId"].(float64)
 		if !ok {
-			return 0, 0, fmt.Errorf("invalid userId claim")
+			return nil, fmt.Errorf("invalid userId claim")
 		}
-		userId := uint(userIdFloat)
 		
 		// Extract role ID if available
 		var roleId uint = 0
@@ -48,14 +83,26 @@ func verifyToken(tokenString string) (uint, uint, error) {
 				roleId = uint(roleIdFloat)
 			}
 		}
+
+		// Every access token carries a jti so it can be revoked
+		jti, ok := claims["jti"].(string)
+		if !ok || jti == "" {
+			return nil, fmt.Errorf("invalid jti claim")
+		}
+		sessionId, _ := claims["sid"].(string)
 		
-		return userId, roleId, nil
+		return &tokenClaims{
+			UserID:    uint(userIdFloat),
+			RoleID:    roleId,
+			JTI:       jti,
+			SessionID: sessionId,
+		}, nil
 	} else {
-		return 0, 0, fmt.Errorf("An error has occurred")
+		return nil, fmt.Errorf("An error has occurred")
 	}
 }
 
-func AuthMiddleware(roles []uint) gin.HandlerFunc {
+func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.Request.Header.Get("Authorization")
 		if authHeader == "" {
@@ -65,28 +112,47 @@ func AuthMiddleware(roles []uint) gin.HandlerFunc {
 		}
 		// Decode token
 		tokenString := authHeader[len("Bearer "):]
-		userId, roleId, err := verifyToken(tokenString)
+		claims, err := verifyToken(tokenString)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid Token"})
 			c.Abort()
 			return
 		}
-
-		authorized := false
-		for _, role := range(roles){
-			if role == roleId || role == 0 {
-				authorized = true
-			}
+		// Reject tokens that were revoked through logout
+		if IsTokenRevoked(claims.JTI) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+			c.Abort()
+			return
 		}
-		if !authorized {
-			fmt.Println(roles, roleId)
+
+		c.Set("userId", claims.UserID)
+		c.Set("roleId", claims.RoleID)
+		c.Set("jti", claims.JTI)
+		c.Set("sessionId", claims.SessionID)
+		c.Next()
+	}
+}
+
+// RequirePermission only lets the request through when the caller's role has been
+// granted the permission. It must run after AuthMiddleware.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasPermission(c, permission) {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied: Insufficient permissions"})
 			c.Abort()
 			return
-
 		}
-		c.Set("userId", userId)
-		c.Set("roleId", roleId)
 		c.Next()
 	}
 }
+
+// HasPermission checks the permission against the current role of the authenticated user.
+// The role is looked up on every request and not taken from the token, so a changed role
+// or revoked permission applies to tokens that were issued before.
+func HasPermission(c *gin.Context, permission string) bool {
+	userId, exists := c.Get("userId")
+	if !exists {
+		return false
+	}
+	return UserHasPermission(userId.(uint), permission)
+}
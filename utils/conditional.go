@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NotModified sets the ETag and Last-Modified headers and answers 304 Not Modified when
+// the request's If-None-Match or If-Modified-Since says the client is up to date. The
+// handler has nothing left to write when it returns true.
+func NotModified(c *gin.Context, etag string, lastModified time.Time) bool {
+	// Clients may keep the response but have to revalidate it before using it
+	c.Header("Cache-Control", "no-cache")
+	c.Header("ETag", etag)
+	if !lastModified.IsZero() {
+		c.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	// If-Modified-Since is ignored when If-None-Match is present
+	notModified := false
+	if match := c.GetHeader("If-None-Match"); match != "" {
+		notModified = etagMatches(match, etag)
+	} else if since, err := http.ParseTime(c.GetHeader("If-Modified-Since")); err == nil && !lastModified.IsZero() {
+		notModified = !lastModified.Truncate(time.Second).After(since)
+	}
+	if notModified {
+		c.Status(http.StatusNotModified)
+	}
+	return notModified
+}
+
+// JSONWithETag writes obj as JSON with an ETag computed from the body, for responses that
+// have no modification time of their own
+func JSONWithETag(c *gin.Context, obj any) {
+	body, err := json.Marshal(obj)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode response"})
+		return
+	}
+	sum := sha256.Sum256(body)
+	if NotModified(c, `"`+hex.EncodeToString(sum[:16])+`"`, time.Time{}) {
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+// etagMatches compares the If-None-Match list with the weak comparison RFC 9110 asks for
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
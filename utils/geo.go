@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// earthRadiusKm is the mean radius of the earth, the distance filter uses the same
+const earthRadiusKm = 6371
+
+// LatLng is a point in decimal degrees
+type LatLng struct {
+	Lat float64
+	Lng float64
+}
+
+// BoundingBox is an area between two latitudes and two longitudes. MinLng may be
+// greater than MaxLng for boxes that cross the antimeridian.
+type BoundingBox struct {
+	MinLat float64
+	MinLng float64
+	MaxLat float64
+	MaxLng float64
+}
+
+// Validate checks that the point lies within valid latitude and longitude ranges
+func (p LatLng) Validate() error {
+	if math.IsNaN(p.Lat) || p.Lat < -90 || p.Lat > 90 {
+		return fmt.Errorf("latitude must be between -90 and 90")
+	}
+	if math.IsNaN(p.Lng) || p.Lng < -180 || p.Lng > 180 {
+		return fmt.Errorf("longitude must be between -180 and 180")
+	}
+	return nil
+}
+
+// ParseLatLng parses a "lat,lng" pair
+func ParseLatLng(value string) (LatLng, error) {
+	values, err := parseCoordinates(value, 2)
+	if err != nil {
+		return LatLng{}, fmt.Errorf("expected lat,lng")
+	}
+	point := LatLng{Lat: values[0], Lng: values[1]}
+	return point, point.Validate()
+}
+
+// ParseBoundingBox parses a "minLng,minLat,maxLng,maxLat" box, the same order GeoJSON uses
+func ParseBoundingBox(value string) (BoundingBox, error) {
+	values, err := parseCoordinates(value, 4)
+	if err != nil {
+		return BoundingBox{}, fmt.Errorf("expected minLng,minLat,maxLng,maxLat")
+	}
+	box := BoundingBox{MinLng: values[0], MinLat: values[1], MaxLng: values[2], MaxLat: values[3]}
+	for _, corner := range []LatLng{{box.MinLat, box.MinLng}, {box.MaxLat, box.MaxLng}} {
+		if err := corner.Validate(); err != nil {
+			return box, err
+		}
+	}
+	if box.MinLat > box.MaxLat {
+		return box, fmt.Errorf("minLat must not be greater than maxLat")
+	}
+	return box, nil
+}
+
+// RadiusBounds returns a box that contains every point within radiusKm of p. The box is
+// only a cheap prefilter, ok is false near the poles and the antimeridian where it
+// would not restrict longitude.
+func (p LatLng) RadiusBounds(radiusKm float64) (box BoundingBox, ok bool) {
+	// The radius as the angle it spans at the earth's center
+	angle := radiusKm / earthRadiusKm
+	latDelta := angle * 180 / math.Pi
+	box.MinLat = math.Max(p.Lat-latDelta, -90)
+	box.MaxLat = math.Min(p.Lat+latDelta, 90)
+	if box.MinLat == -90 || box.MaxLat == 90 {
+		return box, false
+	}
+
+	// The circle is widest poleward of p, not at p's latitude, so the longitude range
+	// is asin(sin(angle) / cos(lat)) rather than the angle divided by cos(lat)
+	sinLngDelta := math.Sin(angle) / math.Cos(p.Lat*math.Pi/180)
+	if sinLngDelta >= 1 {
+		return box, false
+	}
+	lngDelta := math.Asin(sinLngDelta) * 180 / math.Pi
+	box.MinLng = p.Lng - lngDelta
+	box.MaxLng = p.Lng + lngDelta
+	if box.MinLng < -180 || box.MaxLng > 180 {
+		return box, false
+	}
+	return box, true
+}
+
+func parseCoordinates(value string, count int) ([]float64, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != count {
+		return nil, fmt.Errorf("expected %d coordinates", count)
+	}
+	values := make([]float64, count)
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
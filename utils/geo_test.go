@@ -0,0 +1,148 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+// haversineKm is the distance the properties query filters on
+func haversineKm(a, b LatLng) float64 {
+	rad := math.Pi / 180
+	h := math.Pow(math.Sin((b.Lat-a.Lat)*rad/2), 2) +
+		math.Cos(a.Lat*rad)*math.Cos(b.Lat*rad)*math.Pow(math.Sin((b.Lng-a.Lng)*rad/2), 2)
+	return earthRadiusKm * 2 * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// destination is the point distanceKm from p in the direction of bearing (degrees)
+func destination(p LatLng, bearing, distanceKm float64) LatLng {
+	rad := math.Pi / 180
+	angle := distanceKm / earthRadiusKm
+	lat := math.Asin(math.Sin(p.Lat*rad)*math.Cos(angle) + math.Cos(p.Lat*rad)*math.Sin(angle)*math.Cos(bearing*rad))
+	lng := p.Lng*rad + math.Atan2(math.Sin(bearing*rad)*math.Sin(angle)*math.Cos(p.Lat*rad),
+		math.Cos(angle)-math.Sin(p.Lat*rad)*math.Sin(lat))
+	return LatLng{Lat: lat / rad, Lng: math.Remainder(lng/rad, 360)}
+}
+
+func TestRadiusBounds(t *testing.T) {
+	tests := []struct {
+		name     string
+		center   LatLng
+		radiusKm float64
+		ok       bool
+	}{
+		{"equator", LatLng{0, 0}, 100, true},
+		{"berlin", LatLng{52.52, 13.405}, 25, true},
+		{"far north", LatLng{70, 25}, 500, true},
+		{"far south", LatLng{-75, -60}, 300, true},
+		{"near the north pole", LatLng{89.9, 0}, 50, false},
+		{"around the south pole", LatLng{-89.5, 100}, 100, false},
+		{"circle touching a pole", LatLng{80, 0}, 1200, false},
+		{"east of the antimeridian", LatLng{-17, 179.9}, 50, false},
+		{"west of the antimeridian", LatLng{65, -179.95}, 20, false},
+		{"close to the antimeridian", LatLng{-17, 179}, 50, true},
+	}
+	for _, tt := range tests {
+		box, ok := tt.center.RadiusBounds(tt.radiusKm)
+		if ok != tt.ok {
+			t.Errorf("%s: got ok %v, want %v with %+v", tt.name, ok, tt.ok, box)
+			continue
+		}
+		if box.MinLat < -90 || box.MaxLat > 90 {
+			t.Errorf("%s: latitudes out of range in %+v", tt.name, box)
+		}
+
+		// Every point of the circle has to pass the prefilter, also the widest ones
+		// poleward of the center
+		for bearing := 0.0; bearing < 360; bearing += 1 {
+			point := destination(tt.center, bearing, tt.radiusKm*0.9999)
+			if distance := haversineKm(tt.center, point); distance > tt.radiusKm {
+				t.Fatalf("%s: test point is %f km away", tt.name, distance)
+			}
+			if point.Lat < box.MinLat || point.Lat > box.MaxLat {
+				t.Errorf("%s: %+v at bearing %v is outside the latitudes of %+v", tt.name, point, bearing, box)
+			}
+			if ok && (point.Lng < box.MinLng || point.Lng > box.MaxLng) {
+				t.Errorf("%s: %+v at bearing %v is outside the longitudes of %+v", tt.name, point, bearing, box)
+			}
+		}
+	}
+}
+
+func TestParseCoordinates(t *testing.T) {
+	tests := []struct {
+		value string
+		count int
+		want  []float64
+	}{
+		{"52.52,13.405", 2, []float64{52.52, 13.405}},
+		{" -33.9 , 151.2 ", 2, []float64{-33.9, 151.2}},
+		{"1,2,3,4", 4, []float64{1, 2, 3, 4}},
+		{"1,2,3", 2, nil},
+		{"1", 2, nil},
+		{"", 2, nil},
+		{"a,b", 2, nil},
+		{"1;2", 2, nil},
+	}
+	for _, tt := range tests {
+		got, err := parseCoordinates(tt.value, tt.count)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("%q: got %v, want an error", tt.value, got)
+			}
+			continue
+		}
+		if err != nil || len(got) != len(tt.want) {
+			t.Errorf("%q: got %v, %v", tt.value, got, err)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%q: got %v, want %v", tt.value, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestParseLatLng(t *testing.T) {
+	valid := []string{"0,0", "90,180", "-90,-180", "52.52,13.405"}
+	invalid := []string{"91,0", "0,181", "NaN,0", "0,Inf", "52.52", "north,east"}
+	for _, value := range valid {
+		if _, err := ParseLatLng(value); err != nil {
+			t.Errorf("%q: %v", value, err)
+		}
+	}
+	for _, value := range invalid {
+		if _, err := ParseLatLng(value); err == nil {
+			t.Errorf("%q: accepted", value)
+		}
+	}
+}
+
+func TestParseBoundingBox(t *testing.T) {
+	tests := []struct {
+		value string
+		want  *BoundingBox
+	}{
+		{"13.0,52.3,13.8,52.7", &BoundingBox{MinLng: 13.0, MinLat: 52.3, MaxLng: 13.8, MaxLat: 52.7}},
+		// Crossing the antimeridian, MinLng is greater than MaxLng
+		{"170,-20,-170,-10", &BoundingBox{MinLng: 170, MinLat: -20, MaxLng: -170, MaxLat: -10}},
+		// Up to the poles
+		{"-180,80,180,90", &BoundingBox{MinLng: -180, MinLat: 80, MaxLng: 180, MaxLat: 90}},
+		{"13.0,52.7,13.8,52.3", nil},
+		{"13.0,52.3,190,52.7", nil},
+		{"13.0,-91,13.8,52.7", nil},
+		{"13.0,52.3,13.8", nil},
+	}
+	for _, tt := range tests {
+		got, err := ParseBoundingBox(tt.value)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("%q: accepted as %+v", tt.value, got)
+			}
+			continue
+		}
+		if err != nil || got != *tt.want {
+			t.Errorf("%q: got %+v, %v, want %+v", tt.value, got, err, *tt.want)
+		}
+	}
+}
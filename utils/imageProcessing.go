@@ -0,0 +1,237 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// ErrInvalidImage is returned for files that are not an image we accept
+var ErrInvalidImage = errors.New("invalid image")
+
+const (
+	// MaxImageSize is the largest image file that is accepted
+	MaxImageSize = 20 << 20
+	// maxImagePixels guards against small files that decode to huge images
+	maxImagePixels = 50_000_000
+)
+
+// ImageRendition is a resized copy made of every image, fitting in MaxSize x MaxSize
+type ImageRendition struct {
+	Name    string
+	MaxSize int
+}
+
+// ImageRenditions are the renditions stored next to each original
+var ImageRenditions = []ImageRendition{
+	{Name: "thumbnail", MaxSize: 320},
+	{Name: "medium", MaxSize: 1024},
+	{Name: "large", MaxSize: 2048},
+}
+
+// EncodedImage is an image ready to be stored
+type EncodedImage struct {
+	Data        []byte
+	ContentType string
+	Ext         string
+	Width       int
+	Height      int
+}
+
+// ProcessedImage is a cleaned up original and its renditions by name
+type ProcessedImage struct {
+	Original   EncodedImage
+	Renditions map[string]EncodedImage
+}
+
+// ProcessImage accepts JPEG, PNG and WebP images and re-encodes them, which drops EXIF
+// and any other metadata such as GPS coordinates. The EXIF orientation is applied to the
+// pixels first so the image is still shown the right way up.
+func ProcessImage(data []byte) (ProcessedImage, error) {
+	if len(data) > MaxImageSize {
+		return ProcessedImage{}, fmt.Errorf("%w: larger than 20 MB", ErrInvalidImage)
+	}
+	// The format is sniffed from the content, the file name and content type aren't trusted
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || (format != "jpeg" && format != "png" && format != "webp") {
+		return ProcessedImage{}, fmt.Errorf("%w: only JPEG, PNG and WebP images are accepted", ErrInvalidImage)
+	}
+	if config.Width*config.Height > maxImagePixels {
+		return ProcessedImage{}, fmt.Errorf("%w: %dx%d pixels is too large", ErrInvalidImage, config.Width, config.Height)
+	}
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return ProcessedImage{}, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+
+	img := toRGBA(decoded)
+	if format == "jpeg" {
+		img = applyOrientation(img, jpegOrientation(data))
+	}
+
+	// There is no pure Go WebP encoder, so everything is stored as JPEG except PNGs,
+	// which stay lossless, and images with transparency
+	opaque := img.Opaque()
+	original, err := encodeImage(img, format == "png" || !opaque, 90)
+	if err != nil {
+		return ProcessedImage{}, err
+	}
+	processed := ProcessedImage{Original: original, Renditions: make(map[string]EncodedImage, len(ImageRenditions))}
+	for _, rendition := range ImageRenditions {
+		encoded, err := encodeImage(resizeToFit(img, rendition.MaxSize), !opaque, 85)
+		if err != nil {
+			return ProcessedImage{}, err
+		}
+		processed.Renditions[rendition.Name] = encoded
+	}
+	return processed, nil
+}
+
+func encodeImage(img image.Image, lossless bool, quality int) (EncodedImage, error) {
+	var buf bytes.Buffer
+	encoded := EncodedImage{Width: img.Bounds().Dx(), Height: img.Bounds().Dy()}
+	if lossless {
+		if err := png.Encode(&buf, img); err != nil {
+			return EncodedImage{}, fmt.Errorf("failed to encode image: %w", err)
+		}
+		encoded.ContentType, encoded.Ext = "image/png", ".png"
+	} else {
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+			return EncodedImage{}, fmt.Errorf("failed to encode image: %w", err)
+		}
+		encoded.ContentType, encoded.Ext = "image/jpeg", ".jpg"
+	}
+	encoded.Data = buf.Bytes()
+	return encoded, nil
+}
+
+// toRGBA copies the image into an RGBA image with its origin at 0,0
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Rect.Min == (image.Point{}) {
+		return rgba
+	}
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+	return rgba
+}
+
+// resizeToFit scales the image down to fit in size x size, smaller images are kept as is
+func resizeToFit(img *image.RGBA, size int) image.Image {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	if width <= size && height <= size {
+		return img
+	}
+	if width >= height {
+		width, height = size, max(1, height*size/width)
+	} else {
+		width, height = max(1, width*size/height), size
+	}
+	resized := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(resized, resized.Rect, img, img.Rect, draw.Src, nil)
+	return resized
+}
+
+// applyOrientation rotates and flips the image as described by an EXIF orientation value
+func applyOrientation(img *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	bounds := image.Rect(0, 0, width, height)
+	if orientation >= 5 {
+		bounds = image.Rect(0, 0, height, width)
+	}
+	oriented := image.NewRGBA(bounds)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // Mirrored horizontally
+				dx, dy = width-1-x, y
+			case 3: // Rotated 180°
+				dx, dy = width-1-x, height-1-y
+			case 4: // Mirrored vertically
+				dx, dy = x, height-1-y
+			case 5: // Transposed
+				dx, dy = y, x
+			case 6: // Needs a 90° clockwise rotation
+				dx, dy = height-1-y, x
+			case 7: // Transversed
+				dx, dy = height-1-y, width-1-x
+			case 8: // Needs a 90° counter-clockwise rotation
+				dx, dy = y, width-1-x
+			}
+			src := img.PixOffset(x, y)
+			copy(oriented.Pix[oriented.PixOffset(dx, dy):], img.Pix[src:src+4])
+		}
+	}
+	return oriented
+}
+
+// jpegOrientation reads the orientation from the EXIF segment of a JPEG, 1 means as stored
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		if marker == 0xFF { // Fill byte
+			i++
+			continue
+		}
+		if marker == 0xDA || marker == 0xD9 { // Image data starts, metadata comes before it
+			return 1
+		}
+		end := i + 2 + int(binary.BigEndian.Uint16(data[i+2:]))
+		if end < i+4 || end > len(data) {
+			return 1
+		}
+		if marker == 0xE1 && bytes.HasPrefix(data[i+4:end], []byte("Exif\x00\x00")) {
+			return exifOrientation(data[i+10 : end])
+		}
+		i = end
+	}
+	return 1
+}
+
+// exifOrientation finds the orientation tag in the first IFD of the EXIF TIFF structure
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	offset := int(order.Uint32(tiff[4:]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	for i := 0; i < count; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			return int(order.Uint16(tiff[entry+8:]))
+		}
+	}
+	return 1
+}
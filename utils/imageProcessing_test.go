@@ -0,0 +1,238 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// testImage is an opaque image whose pixels all differ, so flips and rotations show
+func testImage(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 40), G: uint8(y * 40), B: 100, A: 255})
+		}
+	}
+	return img
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// exifSegment builds an APP1 segment whose first IFD holds the orientation, followed by
+// some GPS looking bytes
+func exifSegment(order binary.ByteOrder, orientation uint16) []byte {
+	tiff := make([]byte, 8+2+12+4)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8)
+	order.PutUint16(tiff[8:], 1)
+	order.PutUint16(tiff[10:], 0x0112) // Orientation
+	order.PutUint16(tiff[12:], 3)      // SHORT
+	order.PutUint32(tiff[14:], 1)
+	order.PutUint16(tiff[18:], orientation)
+	tiff = append(tiff, "GPSLatitude 52.5200 GPSLongitude 13.4050"...)
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+// withSegment inserts the segment right after the start of image marker
+func withSegment(jpegData, segment []byte) []byte {
+	data := append([]byte{}, jpegData[:2]...)
+	data = append(data, segment...)
+	return append(data, jpegData[2:]...)
+}
+
+// pngHeader is a PNG that only has a valid signature and header, enough for DecodeConfig
+func pngHeader(width, height uint32) []byte {
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr, width)
+	binary.BigEndian.PutUint32(ihdr[4:], height)
+	ihdr[8], ihdr[9] = 8, 6 // 8 bit RGBA
+
+	chunk := make([]byte, 4, 4+4+len(ihdr)+4)
+	binary.BigEndian.PutUint32(chunk, uint32(len(ihdr)))
+	chunk = append(chunk, "IHDR"...)
+	chunk = append(chunk, ihdr...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+	return append([]byte("\x89PNG\r\n\x1a\n"), chunk...)
+}
+
+func TestProcessImageSniffsFormat(t *testing.T) {
+	var pngData, gifData bytes.Buffer
+	png.Encode(&pngData, testImage(4, 3))
+	gif.Encode(&gifData, testImage(4, 3), nil)
+
+	tests := []struct {
+		name        string
+		data        []byte
+		contentType string
+		wantErr     bool
+	}{
+		{"jpeg", encodeJPEG(t, testImage(4, 3)), "image/jpeg", false},
+		{"png", pngData.Bytes(), "image/png", false},
+		{"gif", gifData.Bytes(), "", true},
+		{"text", []byte("<svg xmlns=\"http://www.w3.org/2000/svg\"/>"), "", true},
+		{"empty", nil, "", true},
+		{"too many pixels", pngHeader(10000, 10000), "", true},
+		{"too large", make([]byte, MaxImageSize+1), "", true},
+	}
+	for _, tt := range tests {
+		processed, err := ProcessImage(tt.data)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidImage) {
+				t.Errorf("%s: got %v, want ErrInvalidImage", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if processed.Original.ContentType != tt.contentType {
+			t.Errorf("%s: stored as %s, want %s", tt.name, processed.Original.ContentType, tt.contentType)
+		}
+		if len(processed.Renditions) != len(ImageRenditions) {
+			t.Errorf("%s: got %d renditions, want %d", tt.name, len(processed.Renditions), len(ImageRenditions))
+		}
+	}
+}
+
+func TestProcessImageStripsMetadata(t *testing.T) {
+	data := withSegment(encodeJPEG(t, testImage(4, 3)), exifSegment(binary.BigEndian, 1))
+	processed, err := ProcessImage(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outputs := []EncodedImage{processed.Original}
+	for _, rendition := range processed.Renditions {
+		outputs = append(outputs, rendition)
+	}
+	for _, output := range outputs {
+		for _, marker := range []string{"Exif", "GPS", "\xFF\xE1"} {
+			if bytes.Contains(output.Data, []byte(marker)) {
+				t.Fatalf("%s output still contains %q", output.ContentType, marker)
+			}
+		}
+	}
+}
+
+func TestProcessImageAppliesOrientation(t *testing.T) {
+	data := withSegment(encodeJPEG(t, testImage(4, 2)), exifSegment(binary.LittleEndian, 6))
+	processed, err := ProcessImage(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if processed.Original.Width != 2 || processed.Original.Height != 4 {
+		t.Fatalf("got %dx%d, want the image turned to 2x4", processed.Original.Width, processed.Original.Height)
+	}
+}
+
+func TestApplyOrientation(t *testing.T) {
+	img := testImage(3, 2)
+	at := func(x, y int) color.RGBA { return img.RGBAAt(x, y) }
+
+	// Where the pixels at (0,0) and (2,0) of the 3x2 image end up
+	tests := []struct {
+		orientation   int
+		width, height int
+		topLeft       image.Point
+		topRight      image.Point
+	}{
+		{1, 3, 2, image.Pt(0, 0), image.Pt(2, 0)},
+		{2, 3, 2, image.Pt(2, 0), image.Pt(0, 0)},
+		{3, 3, 2, image.Pt(2, 1), image.Pt(0, 1)},
+		{4, 3, 2, image.Pt(0, 1), image.Pt(2, 1)},
+		{5, 2, 3, image.Pt(0, 0), image.Pt(0, 2)},
+		{6, 2, 3, image.Pt(1, 0), image.Pt(1, 2)},
+		{7, 2, 3, image.Pt(1, 2), image.Pt(1, 0)},
+		{8, 2, 3, image.Pt(0, 2), image.Pt(0, 0)},
+	}
+	for _, tt := range tests {
+		oriented := applyOrientation(img, tt.orientation)
+		if oriented.Rect.Dx() != tt.width || oriented.Rect.Dy() != tt.height {
+			t.Errorf("orientation %d: got %dx%d, want %dx%d", tt.orientation, oriented.Rect.Dx(), oriented.Rect.Dy(), tt.width, tt.height)
+			continue
+		}
+		if got := oriented.RGBAAt(tt.topLeft.X, tt.topLeft.Y); got != at(0, 0) {
+			t.Errorf("orientation %d: pixel (0,0) isn't at %v", tt.orientation, tt.topLeft)
+		}
+		if got := oriented.RGBAAt(tt.topRight.X, tt.topRight.Y); got != at(2, 0) {
+			t.Errorf("orientation %d: pixel (2,0) isn't at %v", tt.orientation, tt.topRight)
+		}
+	}
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	jpegData := encodeJPEG(t, testImage(4, 3))
+	for orientation := uint16(1); orientation <= 8; orientation++ {
+		for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+			data := withSegment(jpegData, exifSegment(order, orientation))
+			if got := jpegOrientation(data); got != int(orientation) {
+				t.Errorf("%v orientation %d: got %d", order, orientation, got)
+			}
+		}
+	}
+	if got := jpegOrientation(jpegData); got != 1 {
+		t.Errorf("without EXIF: got %d, want 1", got)
+	}
+}
+
+func TestJPEGOrientationMalformed(t *testing.T) {
+	jpegData := encodeJPEG(t, testImage(4, 3))
+	valid := withSegment(jpegData, exifSegment(binary.BigEndian, 6))
+
+	// Cut the data at every length, none of it may panic
+	for n := 0; n < len(valid); n++ {
+		jpegOrientation(valid[:n])
+	}
+
+	segment := func(change func(tiff []byte)) []byte {
+		s := exifSegment(binary.BigEndian, 6)
+		change(s[10:])
+		return withSegment(jpegData, s)
+	}
+	tests := map[string][]byte{
+		"segment length past the end": withSegment(jpegData, []byte{0xFF, 0xE1, 0xFF, 0xFF, 'E', 'x', 'i', 'f', 0, 0}),
+		"segment length too small":    withSegment(jpegData, []byte{0xFF, 0xE1, 0x00, 0x01}),
+		"unknown byte order":          segment(func(tiff []byte) { copy(tiff, "XX") }),
+		"IFD offset past the end":     segment(func(tiff []byte) { binary.BigEndian.PutUint32(tiff[4:], 0xFFFFFFF0) }),
+		"IFD offset inside header":    segment(func(tiff []byte) { binary.BigEndian.PutUint32(tiff[4:], 2) }),
+		"entry count past the end": segment(func(tiff []byte) {
+			binary.BigEndian.PutUint16(tiff[8:], 0xFFFF)
+			binary.BigEndian.PutUint16(tiff[10:], 0x010F) // Make, so the walk runs off the end
+		}),
+		"no EXIF header": withSegment(jpegData, []byte{0xFF, 0xE1, 0x00, 0x04, 'E', 'x'}),
+		"not a JPEG":     []byte("GIF89a"),
+	}
+	for name, data := range tests {
+		if got := jpegOrientation(data); got != 1 {
+			t.Errorf("%s: got orientation %d, want 1", name, got)
+		}
+	}
+	if got := exifOrientation([]byte("MM\x00\x2a")); got != 1 {
+		t.Errorf("short TIFF header: got %d, want 1", got)
+	}
+}
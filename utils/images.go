@@ -2,42 +2,99 @@ package utils
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"mime/multipart"
 	"net/http"
 	"path/filepath"
+	"sort"
 	"sync"
 
+	"golang-test/storage"
+
 	"github.com/gin-gonic/gin"
 )
 
 // FileUploadTask represents a task for uploading a file
 type FileUploadTask struct {
-	Index      int
-	File       *multipart.FileHeader
-	BucketName string
+	Index       int
+	File        *multipart.FileHeader
+	SourceKey   string // Set instead of File for images clients uploaded to storage directly
+	Storage     storage.Storage
 	ImagePrefix string
 }
 
+func (t FileUploadTask) name() string {
+	if t.File != nil {
+		return t.File.Filename
+	}
+	return t.SourceKey
+}
+
 // FileUploadResult represents the result of a file upload task
 type FileUploadResult struct {
-	Index   int
-	Success bool
-	Error   error
-	FileKey string
+	Index       int
+	Success     bool
+	Error       error
+	FileKey     string
+	ContentType string
+	Size        int64
+	Width       int
+	Height      int
+	Renditions  map[string]string // Rendition name to key
+}
+
+// Keys returns the keys of the original and of every rendition
+func (r FileUploadResult) Keys() []string {
+	keys := []string{r.FileKey}
+	for _, key := range r.Renditions {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// uploadFilesWithWorkerPool uses a worker pool to process and upload images concurrently.
+// The results of the uploaded files are returned in the order the files were given.
+func UploadFilesWithWorkerPool(c *gin.Context, store storage.Storage, imagePrefix string, files []*multipart.FileHeader) ([]FileUploadResult, bool) {
+	tasks := make([]FileUploadTask, 0, len(files))
+	for i, file := range files {
+		tasks = append(tasks, FileUploadTask{
+			Index:       i,
+			File:        file,
+			Storage:     store,
+			ImagePrefix: imagePrefix,
+		})
+	}
+	return runUploadTasks(c, store, tasks)
+}
+
+// ProcessStoredImages processes images that clients uploaded to storage directly, the
+// results are stored under new keys and the uploaded files are left for the caller to remove
+func ProcessStoredImages(c *gin.Context, store storage.Storage, imagePrefix string, keys []string) ([]FileUploadResult, bool) {
+	tasks := make([]FileUploadTask, 0, len(keys))
+	for i, key := range keys {
+		tasks = append(tasks, FileUploadTask{
+			Index:       i,
+			SourceKey:   key,
+			Storage:     store,
+			ImagePrefix: imagePrefix,
+		})
+	}
+	return runUploadTasks(c, store, tasks)
 }
 
-// uploadFilesWithWorkerPool uses a worker pool to upload files concurrently
-func UploadFilesWithWorkerPool(c *gin.Context, bucketName, imagePrefix string, files []*multipart.FileHeader) bool {
+func runUploadTasks(c *gin.Context, store storage.Storage, uploadTasks []FileUploadTask) ([]FileUploadResult, bool) {
 	numWorkers := 3 // Configure the number of workers based on your needs
-	if len(files) < numWorkers {
-		numWorkers = len(files)
+	if len(uploadTasks) < numWorkers {
+		numWorkers = len(uploadTasks)
 	}
 	
 	// Create channels for tasks and results
-	tasks := make(chan FileUploadTask, len(files))
-	results := make(chan FileUploadResult, len(files))
+	tasks := make(chan FileUploadTask, len(uploadTasks))
+	results := make(chan FileUploadResult, len(uploadTasks))
 	
 	// Start worker pool
 	var wg sync.WaitGroup
@@ -53,34 +110,46 @@ func UploadFilesWithWorkerPool(c *gin.Context, bucketName, imagePrefix string, f
 	}()
 	
 	// Queue tasks for workers
-	for i, file := range files {
-		tasks <- FileUploadTask{
-			Index:       i,
-			File:        file,
-			BucketName:  bucketName,
-			ImagePrefix: imagePrefix,
-		}
+	for _, task := range uploadTasks {
+		tasks <- task
 	}
 	close(tasks) // No more tasks will be added
 	
-	// Process results and check for failures
-	uploadedFiles := make(map[int]string)
+	// Wait for every upload to finish before deciding, so nothing is still being
+	// uploaded while a failed batch is rolled back
+	var uploaded []FileUploadResult
+	var failures []gin.H
+	status := http.StatusInternalServerError
 	for result := range results {
 		if result.Error != nil || !result.Success {
-			// If any upload fails, cleanup files already uploaded and return failure
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to upload file %d: %v", result.Index, result.Error)})
-			
-			// Clean up all files that were successfully uploaded
-			for idx, fileKey := range uploadedFiles {
-				DeleteFileFromS3(bucketName, fileKey)
-				delete(uploadedFiles, idx) // Remove from map after cleanup
+			failures = append(failures, gin.H{
+				"index":    result.Index,
+				"filename": uploadTasks[result.Index].name(),
+				"error":    result.Error.Error(),
+			})
+			if errors.Is(result.Error, ErrInvalidImage) {
+				status = http.StatusBadRequest
 			}
-			return false
+			continue
 		}
-		uploadedFiles[result.Index] = result.FileKey
+		uploaded = append(uploaded, result)
 	}
 	
-	return true
+	if len(failures) > 0 {
+		// Roll back the files that did upload and report each failed one
+		var keys []string
+		for _, result := range uploaded {
+			keys = append(keys, result.Keys()...)
+		}
+		CleanupFiles(store, keys)
+		
+		sort.Slice(failures, func(i, j int) bool { return failures[i]["index"].(int) < failures[j]["index"].(int) })
+		c.JSON(status, gin.H{"error": fmt.Sprintf("Failed to upload %d of %d files", len(failures), len(uploadTasks)), "files": failures})
+		return nil, false
+	}
+	
+	sort.Slice(uploaded, func(i, j int) bool { return uploaded[i].Index < uploaded[j].Index })
+	return uploaded, true
 }
 
 // fileUploadWorker processes file upload tasks
@@ -89,47 +158,113 @@ func fileUploadWorker(tasks <-chan FileUploadTask, results chan<- FileUploadResu
 	
 	for task := range tasks {
 		// Process the file upload
-		fileKey, err := uploadFileToS3(task)
-		
-		result := FileUploadResult{
-			Index:   task.Index,
-			Success: err == nil,
-			Error:   err,
-			FileKey: fileKey,
-		}
+		result, err := uploadFile(task)
+		result.Index = task.Index
+		result.Success = err == nil
+		result.Error = err
 		
 		results <- result
 	}
 }
 
-// uploadFileToS3 handles the actual upload to S3
-func uploadFileToS3(task FileUploadTask) (string, error) {
-	// Open the uploaded file
+// uploadFile processes the image and uploads it to storage with its renditions
+func uploadFile(task FileUploadTask) (FileUploadResult, error) {
+	data, err := readUploadedFile(task)
+	if err != nil {
+		return FileUploadResult{}, err
+	}
+	processed, err := ProcessImage(data)
+	if err != nil {
+		return FileUploadResult{}, err
+	}
+	
+	// The extension comes from the format the image is stored in, not from the upload
+	baseKey, err := NewImageKey(task.ImagePrefix, task.Index, "")
+	if err != nil {
+		return FileUploadResult{}, err
+	}
+	result := FileUploadResult{
+		FileKey:     baseKey + processed.Original.Ext,
+		ContentType: processed.Original.ContentType,
+		Size:        int64(len(processed.Original.Data)),
+		Width:       processed.Original.Width,
+		Height:      processed.Original.Height,
+		Renditions:  make(map[string]string, len(processed.Renditions)),
+	}
+	
+	// Upload to storage, removing what was stored already if one of the files fails
+	var stored []string
+	put := func(key string, image EncodedImage) error {
+		if err := task.Storage.Put(context.Background(), key, image.Data, image.ContentType); err != nil {
+			CleanupFiles(task.Storage, stored)
+			return fmt.Errorf("failed to upload file: %w", err)
+		}
+		stored = append(stored, key)
+		return nil
+	}
+	if err := put(result.FileKey, processed.Original); err != nil {
+		return FileUploadResult{}, err
+	}
+	for name, rendition := range processed.Renditions {
+		key := baseKey + "_" + name + rendition.Ext
+		if err := put(key, rendition); err != nil {
+			return FileUploadResult{}, err
+		}
+		result.Renditions[name] = key
+	}
+	
+	return result, nil
+}
+
+// readUploadedFile reads the multipart file or the file the client uploaded to storage
+func readUploadedFile(task FileUploadTask) ([]byte, error) {
+	if task.File == nil {
+		info, err := task.Storage.Stat(context.Background(), task.SourceKey)
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, fmt.Errorf("%w: file has not been uploaded", ErrInvalidImage)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read uploaded file: %w", err)
+		}
+		if info.Size > MaxImageSize {
+			return nil, fmt.Errorf("%w: larger than 20 MB", ErrInvalidImage)
+		}
+		data, err := task.Storage.Get(context.Background(), task.SourceKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read uploaded file: %w", err)
+		}
+		return data, nil
+	}
+	
+	if task.File.Size > MaxImageSize {
+		return nil, fmt.Errorf("%w: larger than 20 MB", ErrInvalidImage)
+	}
 	src, err := task.File.Open()
 	if err != nil {
-		return "", fmt.Errorf("failed to open uploaded file: %w", err)
+		return nil, fmt.Errorf("failed to open uploaded file: %w", err)
 	}
 	defer src.Close()
 	
-	// Read the file content
 	fileBytes := bytes.Buffer{}
 	if _, err := io.Copy(&fileBytes, src); err != nil {
-		return "", fmt.Errorf("failed to read file content: %w", err)
+		return nil, fmt.Errorf("failed to read file content: %w", err)
 	}
-	
-	// Create a unique key for this file
-	fileKey := fmt.Sprintf("%s/image_%d%s", task.ImagePrefix, task.Index, filepath.Ext(task.File.Filename))
-	
-	// Upload to S3
-	if err := UploadFileToS3(task.BucketName, fileKey, fileBytes.Bytes()); err != nil {
-		return "", fmt.Errorf("failed to upload file to S3: %w", err)
+	return fileBytes.Bytes(), nil
+}
+
+// NewImageKey creates a unique key for an image of a property, images can be added after
+// the property is created so an index alone could collide with an existing key
+func NewImageKey(imagePrefix string, index int, filename string) (string, error) {
+	suffix, err := GenerateRandomToken(6)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate file key: %w", err)
 	}
-	
-	return fileKey, nil
+	return fmt.Sprintf("%s/image_%d_%s%s", imagePrefix, index, suffix, filepath.Ext(filename)), nil
 }
 
-// cleanupS3Files removes all uploaded files in case of failure
-func cleanupS3Files(bucketName, imagePrefix string, count int) {
+// CleanupFiles removes the given files using a small worker pool
+func CleanupFiles(store storage.Storage, fileKeys []string) {
+	count := len(fileKeys)
 	// Create a worker pool for deletion
 	numWorkers := 3
 	if count < numWorkers {
@@ -146,14 +281,15 @@ func cleanupS3Files(bucketName, imagePrefix string, count int) {
 		go func() {
 			defer wg.Done()
 			for fileKey := range tasks {
-				DeleteFileFromS3(bucketName, fileKey)
+				if err := store.Delete(context.Background(), fileKey); err != nil {
+					log.Printf("Failed to delete file %s: %v", fileKey, err)
+				}
 			}
 		}()
 	}
 	
 	// Queue deletion tasks
-	for i := 0; i < count; i++ {
-		fileKey := fmt.Sprintf("%s/image_%d", imagePrefix, i)
+	for _, fileKey := range fileKeys {
 		tasks <- fileKey
 	}
 	
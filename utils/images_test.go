@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"golang-test/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// failingPuts fails storing the files of the image with the given index
+type failingPuts struct {
+	*storage.Memory
+	index string
+}
+
+func (f failingPuts) Put(ctx context.Context, key string, body []byte, contentType string) error {
+	if strings.Contains(key, "/image_"+f.index+"_") {
+		return errors.New("connection reset")
+	}
+	return f.Memory.Put(ctx, key, body, contentType)
+}
+
+// uploadSources stores the images as if clients uploaded them directly
+func uploadSources(t *testing.T, store storage.Storage, images ...[]byte) []string {
+	t.Helper()
+	var keys []string
+	for i, data := range images {
+		key := "uploads/" + string(rune('a'+i))
+		if err := store.Put(context.Background(), key, data, "image/jpeg"); err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func TestRunUploadTasks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	valid := encodeJPEG(t, testImage(4, 3))
+
+	tests := []struct {
+		name       string
+		images     [][]byte
+		failIndex  string
+		wantStatus int
+	}{
+		{"all uploaded", [][]byte{valid, valid, valid}, "", http.StatusOK},
+		{"invalid image", [][]byte{valid, []byte("not an image"), valid}, "", http.StatusBadRequest},
+		{"storage failure", [][]byte{valid, valid, valid}, "1", http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		memory := storage.NewMemory()
+		var store storage.Storage = memory
+		if tt.failIndex != "" {
+			store = failingPuts{Memory: memory, index: tt.failIndex}
+		}
+		sources := uploadSources(t, store, tt.images...)
+
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		results, ok := ProcessStoredImages(c, store, "property/property_1", sources)
+
+		keys := memory.Keys()
+		sort.Strings(keys)
+		if tt.wantStatus != http.StatusOK {
+			// Every file of the images that did upload is removed again
+			if ok || results != nil || recorder.Code != tt.wantStatus {
+				t.Errorf("%s: got ok %v and status %d, want %d", tt.name, ok, recorder.Code, tt.wantStatus)
+			}
+			if strings.Join(keys, ",") != strings.Join(sources, ",") {
+				t.Errorf("%s: left %v in storage, want only the sources", tt.name, keys)
+			}
+			if !strings.Contains(recorder.Body.String(), `"index":1`) {
+				t.Errorf("%s: the response doesn't name the failed file: %s", tt.name, recorder.Body)
+			}
+			continue
+		}
+
+		if !ok || len(results) != len(tt.images) {
+			t.Fatalf("%s: got ok %v with %d results", tt.name, ok, len(results))
+		}
+		for i, result := range results {
+			if result.Index != i || len(result.Renditions) != len(ImageRenditions) {
+				t.Errorf("%s: unexpected result %+v", tt.name, result)
+			}
+		}
+		// The sources plus an original and the renditions of every image
+		if want := len(sources) + len(tt.images)*(1+len(ImageRenditions)); len(keys) != want {
+			t.Errorf("%s: got %d objects, want %d", tt.name, len(keys), want)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"golang-test/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// jwtKey is a single key that can verify tokens and, for the active key, sign them
+type jwtKey struct {
+	ID     string
+	Method jwt.SigningMethod
+	Sign   interface{}
+	Verify interface{}
+}
+
+// JWTKeySet holds the active signing key and every key that is still accepted for verification
+type JWTKeySet struct {
+	signing *jwtKey
+	keys    map[string]*jwtKey
+	order   []string
+}
+
+var jwtKeys *JWTKeySet
+
+// LoadJWTKeys builds the key set from the configuration. With JWT_SIGNING_KEY set, the PEM
+// private key at that path is used and its algorithm (RS256 or ES256/384/512) is derived
+// from the key type, otherwise JWT_SECRET is used with HS256. Keys listed in
+// JWT_VERIFICATION_KEYS keep validating tokens signed before a rotation. The list is comma
+// separated, with kid=path for a PEM public key or kid=secret:<value> for an HS256 secret.
+func LoadJWTKeys(cfg config.Config) error {
+	keySet := &JWTKeySet{keys: map[string]*jwtKey{}}
+
+	keyID := cfg.JWTKeyID
+	if keyID == "" {
+		keyID = "default"
+	}
+
+	switch {
+	case cfg.JWTSigningKey != "":
+		pemBytes, err := os.ReadFile(cfg.JWTSigningKey)
+		if err != nil {
+			return fmt.Errorf("failed to read JWT signing key: %w", err)
+		}
+		key, err := parsePrivateKey(keyID, pemBytes)
+		if err != nil {
+			return err
+		}
+		keySet.add(key)
+		keySet.signing = key
+	case cfg.JWTSecret != "":
+		key := newSecretKey(keyID, cfg.JWTSecret)
+		keySet.add(key)
+		keySet.signing = key
+	default:
+		return fmt.Errorf("either JWT_SIGNING_KEY or JWT_SECRET must be set")
+	}
+
+	for _, entry := range strings.Split(cfg.JWTVerificationKeys, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("invalid JWT verification key entry %q, expected kid=path or kid=secret:<value>", entry)
+		}
+		if _, exists := keySet.keys[parts[0]]; exists {
+			return fmt.Errorf("duplicate JWT key id %q", parts[0])
+		}
+		if secret, ok := strings.CutPrefix(parts[1], "secret:"); ok {
+			if secret == "" {
+				return fmt.Errorf("JWT verification key %q has an empty secret", parts[0])
+			}
+			keySet.add(newSecretKey(parts[0], secret))
+			continue
+		}
+		pemBytes, err := os.ReadFile(parts[1])
+		if err != nil {
+			return fmt.Errorf("failed to read JWT verification key %q: %w", parts[0], err)
+		}
+		key, err := parsePublicKey(parts[0], pemBytes)
+		if err != nil {
+			return err
+		}
+		keySet.add(key)
+	}
+
+	jwtKeys = keySet
+	return nil
+}
+
+func (k *JWTKeySet) add(key *jwtKey) {
+	k.keys[key.ID] = key
+	k.order = append(k.order, key.ID)
+}
+
+// SignToken signs the claims with the active key and sets the kid header
+func SignToken(claims jwt.Claims) (string, error) {
+	if jwtKeys == nil {
+		return "", fmt.Errorf("JWT keys are not loaded")
+	}
+	token := jwt.NewWithClaims(jwtKeys.signing.Method, claims)
+	token.Header["kid"] = jwtKeys.signing.ID
+	return token.SignedString(jwtKeys.signing.Sign)
+}
+
+// verificationKey looks up the key named by the token's kid header. Tokens without a kid
+// were issued before key ids existed and are checked against the active key.
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	if jwtKeys == nil {
+		return nil, fmt.Errorf("JWT keys are not loaded")
+	}
+	key := jwtKeys.signing
+	if kid, ok := token.Header["kid"].(string); ok {
+		key, ok = jwtKeys.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+	}
+	// The algorithm is bound to the key so a token can't pick a weaker one
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+	}
+	return key.Verify, nil
+}
+
+// JWKS returns the public verification keys in JSON Web Key Set format. Shared secrets
+// are never published.
+func JWKS() map[string]interface{} {
+	keys := []map[string]interface{}{}
+	if jwtKeys != nil {
+		for _, id := range jwtKeys.order {
+			if jwk := jwtKeys.keys[id].jwk(); jwk != nil {
+				keys = append(keys, jwk)
+			}
+		}
+	}
+	return map[string]interface{}{"keys": keys}
+}
+
+func (k *jwtKey) jwk() map[string]interface{} {
+	switch pub := k.Verify.(type) {
+	case *rsa.PublicKey:
+		return map[string]interface{}{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": k.Method.Alg(),
+			"kid": k.ID,
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		return map[string]interface{}{
+			"kty": "EC",
+			"use": "sig",
+			"alg": k.Method.Alg(),
+			"kid": k.ID,
+			"crv": pub.Curve.Params().Name,
+			"x":   base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size))),
+			"y":   base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size))),
+		}
+	}
+	return nil
+}
+
+func parsePrivateKey(id string, pemBytes []byte) (*jwtKey, error) {
+	if rsaKey, err := jwt.ParseRSAPrivateKeyFromPEM(pemBytes); err == nil {
+		return newAsymmetricKey(id, rsaKey, rsaKey.Public())
+	}
+	if ecKey, err := jwt.ParseECPrivateKeyFromPEM(pemBytes); err == nil {
+		return newAsymmetricKey(id, ecKey, ecKey.Public())
+	}
+	return nil, fmt.Errorf("JWT signing key %q is not an RSA or EC private key", id)
+}
+
+func parsePublicKey(id string, pemBytes []byte) (*jwtKey, error) {
+	if rsaKey, err := jwt.ParseRSAPublicKeyFromPEM(pemBytes); err == nil {
+		return newAsymmetricKey(id, nil, rsaKey)
+	}
+	if ecKey, err := jwt.ParseECPublicKeyFromPEM(pemBytes); err == nil {
+		return newAsymmetricKey(id, nil, ecKey)
+	}
+	return nil, fmt.Errorf("JWT verification key %q is not an RSA or EC public key", id)
+}
+
+func newSecretKey(id, secret string) *jwtKey {
+	return &jwtKey{ID: id, Method: jwt.SigningMethodHS256, Sign: []byte(secret), Verify: []byte(secret)}
+}
+
+func newAsymmetricKey(id string, private interface{}, public crypto.PublicKey) (*jwtKey, error) {
+	key := &jwtKey{ID: id, Sign: private, Verify: public}
+	switch pub := public.(type) {
+	case *rsa.PublicKey:
+		key.Method = jwt.SigningMethodRS256
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256():
+			key.Method = jwt.SigningMethodES256
+		case elliptic.P384():
+			key.Method = jwt.SigningMethodES384
+		case elliptic.P521():
+			key.Method = jwt.SigningMethodES512
+		default:
+			return nil, fmt.Errorf("JWT key %q uses an unsupported curve", id)
+		}
+	default:
+		return nil, fmt.Errorf("JWT key %q has an unsupported type", id)
+	}
+	return key, nil
+}
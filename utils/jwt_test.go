@@ -0,0 +1,261 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang-test/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// writePEM writes the block to a file in the test's directory and returns its path
+func writePEM(t *testing.T, name, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func writePublicKey(t *testing.T, name string, key interface{}) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writePEM(t, name, "PUBLIC KEY", der)
+}
+
+// loadKeys loads the key set and restores the previous one when the test ends
+func loadKeys(t *testing.T, cfg config.Config) {
+	t.Helper()
+	previous := jwtKeys
+	t.Cleanup(func() { jwtKeys = previous })
+	if err := LoadJWTKeys(cfg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func accessClaims() jwt.MapClaims {
+	return jwt.MapClaims{"userId": 7, "roleId": 2, "jti": "token-id", "sid": "session-id"}
+}
+
+// signWith signs the claims with any method, key and kid, bypassing the key set
+func signWith(t *testing.T, method jwt.SigningMethod, key interface{}, kid string) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, accessClaims())
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestParseJWTKeys(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	pkcs8, _ := x509.MarshalPKCS8PrivateKey(rsaKey)
+	p256, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	p384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	p521, _ := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	der := func(key *ecdsa.PrivateKey) []byte {
+		b, _ := x509.MarshalECPrivateKey(key)
+		return b
+	}
+	block := func(blockType string, b []byte) []byte {
+		return pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: b})
+	}
+
+	tests := []struct {
+		name string
+		pem  []byte
+		alg  string
+	}{
+		{"rsa pkcs1", block("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey)), "RS256"},
+		{"rsa pkcs8", block("PRIVATE KEY", pkcs8), "RS256"},
+		{"p256", block("EC PRIVATE KEY", der(p256)), "ES256"},
+		{"p384", block("EC PRIVATE KEY", der(p384)), "ES384"},
+		{"p521", block("EC PRIVATE KEY", der(p521)), "ES512"},
+		{"public key", block("PUBLIC KEY", x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey)), ""},
+		{"not a key", []byte("not a key"), ""},
+	}
+	for _, tt := range tests {
+		key, err := parsePrivateKey("kid", tt.pem)
+		if tt.alg == "" {
+			if err == nil {
+				t.Errorf("%s: parsed as a private key", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if key.Method.Alg() != tt.alg || key.Sign == nil || key.Verify == nil {
+			t.Errorf("%s: got %s, want %s with both halves of the key", tt.name, key.Method.Alg(), tt.alg)
+		}
+	}
+
+	publicDER, _ := x509.MarshalPKIXPublicKey(&p384.PublicKey)
+	key, err := parsePublicKey("kid", block("PUBLIC KEY", publicDER))
+	if err != nil || key.Method.Alg() != "ES384" || key.Sign != nil {
+		t.Fatalf("got %+v, %v for an EC public key", key, err)
+	}
+	if _, err := parsePublicKey("kid", block("EC PRIVATE KEY", der(p256))); err == nil {
+		t.Fatal("a private key was accepted as a verification key")
+	}
+}
+
+func TestLoadJWTKeysErrors(t *testing.T) {
+	p256, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	publicKey := writePublicKey(t, "old.pem", &p256.PublicKey)
+
+	tests := map[string]config.Config{
+		"no key":            {},
+		"missing file":      {JWTSigningKey: filepath.Join(t.TempDir(), "missing.pem")},
+		"public signing":    {JWTSigningKey: publicKey},
+		"entry without kid": {JWTSecret: "secret", JWTVerificationKeys: "=" + publicKey},
+		"entry without key": {JWTSecret: "secret", JWTVerificationKeys: "old"},
+		"empty secret":      {JWTSecret: "secret", JWTVerificationKeys: "old=secret:"},
+		"duplicate kid":     {JWTSecret: "secret", JWTVerificationKeys: "old=" + publicKey + ",old=secret:x"},
+		"active kid reused": {JWTSecret: "secret", JWTKeyID: "current", JWTVerificationKeys: "current=secret:x"},
+	}
+	for name, cfg := range tests {
+		previous := jwtKeys
+		if err := LoadJWTKeys(cfg); err == nil {
+			t.Errorf("%s: loaded without an error", name)
+		}
+		jwtKeys = previous
+	}
+}
+
+func TestVerifyTokenBindsAlgorithmToKey(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	rsaPublic := writePublicKey(t, "rsa.pem", &rsaKey.PublicKey)
+	ecPublic := writePublicKey(t, "ec.pem", &ecKey.PublicKey)
+	rsaPEM, _ := os.ReadFile(rsaPublic)
+
+	loadKeys(t, config.Config{
+		JWTSecret:           "current-secret",
+		JWTKeyID:            "hs",
+		JWTVerificationKeys: "rsa=" + rsaPublic + ", ec=" + ecPublic,
+	})
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"rsa", signWith(t, jwt.SigningMethodRS256, rsaKey, "rsa"), true},
+		{"ec", signWith(t, jwt.SigningMethodES256, ecKey, "ec"), true},
+		{"hs", signWith(t, jwt.SigningMethodHS256, []byte("current-secret"), "hs"), true},
+		// HS256 with the published public key as the secret
+		{"hs under rsa key", signWith(t, jwt.SigningMethodHS256, rsaPEM, "rsa"), false},
+		{"hs under ec key", signWith(t, jwt.SigningMethodHS256, []byte("current-secret"), "ec"), false},
+		{"rs under hs key", signWith(t, jwt.SigningMethodRS256, rsaKey, "hs"), false},
+		{"es under rsa key", signWith(t, jwt.SigningMethodES256, ecKey, "rsa"), false},
+		{"rs384 under rsa key", signWith(t, jwt.SigningMethodRS384, rsaKey, "rsa"), false},
+		{"none", signWith(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "hs"), false},
+	}
+	for _, tt := range tests {
+		_, err := verifyToken(tt.token)
+		if (err == nil) != tt.valid {
+			t.Errorf("%s: got error %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
+
+func TestVerifyTokenSelectsKeyByID(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	loadKeys(t, config.Config{
+		JWTSigningKey: writePEM(t, "signing.pem", "EC PRIVATE KEY", func() []byte {
+			der, _ := x509.MarshalECPrivateKey(ecKey)
+			return der
+		}()),
+		JWTKeyID:            "2026-10",
+		JWTVerificationKeys: "2026-04=secret:old-secret",
+	})
+
+	signed, err := SignToken(accessClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := jwt.NewParser().ParseUnverified(signed, jwt.MapClaims{})
+	if err != nil || token.Header["kid"] != "2026-10" || token.Method.Alg() != "ES256" {
+		t.Fatalf("signed with %v and kid %v", token.Method.Alg(), token.Header["kid"])
+	}
+	claims, err := verifyToken(signed)
+	if err != nil || claims.UserID != 7 || claims.RoleID != 2 || claims.JTI != "token-id" || claims.SessionID != "session-id" {
+		t.Fatalf("got %+v, %v", claims, err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		// Tokens signed with the secret before the rotation stay valid
+		{"rotated secret", signWith(t, jwt.SigningMethodHS256, []byte("old-secret"), "2026-04"), true},
+		{"wrong secret", signWith(t, jwt.SigningMethodHS256, []byte("guessed"), "2026-04"), false},
+		{"unknown kid", signWith(t, jwt.SigningMethodES256, ecKey, "2025-01"), false},
+		// Tokens without a kid are checked against the active key
+		{"no kid", signWith(t, jwt.SigningMethodES256, ecKey, ""), true},
+		{"no kid with old secret", signWith(t, jwt.SigningMethodHS256, []byte("old-secret"), ""), false},
+	}
+	for _, tt := range tests {
+		_, err := verifyToken(tt.token)
+		if (err == nil) != tt.valid {
+			t.Errorf("%s: got error %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	loadKeys(t, config.Config{
+		JWTSigningKey:       writePEM(t, "signing.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey)),
+		JWTVerificationKeys: "ec=" + writePublicKey(t, "ec.pem", &ecKey.PublicKey) + ",hs=secret:never-published",
+	})
+
+	keys := JWKS()["keys"].([]map[string]interface{})
+	if len(keys) != 2 {
+		t.Fatalf("got %d keys, want the RSA and EC key without the secret", len(keys))
+	}
+	rsaJWK, ecJWK := keys[0], keys[1]
+	if rsaJWK["kty"] != "RSA" || rsaJWK["alg"] != "RS256" || rsaJWK["kid"] != "default" || rsaJWK["e"] != "AQAB" {
+		t.Errorf("unexpected RSA key %v", rsaJWK)
+	}
+	if _, private := rsaJWK["d"]; private {
+		t.Error("the RSA key exposes its private exponent")
+	}
+	if ecJWK["kty"] != "EC" || ecJWK["alg"] != "ES384" || ecJWK["kid"] != "ec" || ecJWK["crv"] != "P-384" {
+		t.Errorf("unexpected EC key %v", ecJWK)
+	}
+	// Coordinates are padded to the curve size
+	for _, coordinate := range []string{"x", "y"} {
+		if encoded := ecJWK[coordinate].(string); len(encoded) != 64 {
+			t.Errorf("%s has %d characters, want 64 for 48 bytes", coordinate, len(encoded))
+		}
+	}
+	for _, key := range keys {
+		for _, value := range key {
+			if strings.Contains(value.(string), "never-published") {
+				t.Fatal("the secret is published")
+			}
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// Pagination holds the paging and sorting parameters of a list request
+type Pagination struct {
+	Page     int
+	PageSize int
+	Sort     string
+	Cursor   string
+}
+
+// ParsePagination reads page, pageSize, sort and cursor from the query string.
+// The sort value must be one of allowedSorts, an empty value falls back to defaultSort.
+func ParsePagination(c *gin.Context, allowedSorts []string, defaultSort string) (Pagination, error) {
+	pagination := Pagination{
+		Page:     1,
+		PageSize: DefaultPageSize,
+		Sort:     defaultSort,
+		Cursor:   c.Query("cursor"),
+	}
+
+	if page := c.Query("page"); page != "" {
+		value, err := strconv.Atoi(page)
+		if err != nil || value < 1 {
+			return pagination, fmt.Errorf("page must be a positive integer")
+		}
+		pagination.Page = value
+	}
+
+	if pageSize := c.Query("pageSize"); pageSize != "" {
+		value, err := strconv.Atoi(pageSize)
+		if err != nil || value < 1 || value > MaxPageSize {
+			return pagination, fmt.Errorf("pageSize must be between 1 and %d", MaxPageSize)
+		}
+		pagination.PageSize = value
+	}
+
+	if sort := c.Query("sort"); sort != "" {
+		valid := false
+		for _, allowed := range allowedSorts {
+			if sort == allowed {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return pagination, fmt.Errorf("sort must be one of %v", allowedSorts)
+		}
+		pagination.Sort = sort
+	}
+
+	return pagination, nil
+}
+
+// Offset returns the number of rows to skip for page based pagination
+func (p Pagination) Offset() int {
+	return (p.Page - 1) * p.PageSize
+}
+
+// CacheParams returns the pagination values to include in a cache key
+func (p Pagination) CacheParams() map[string]string {
+	params := map[string]string{
+		"pageSize": strconv.Itoa(p.PageSize),
+		"sort":     p.Sort,
+	}
+	if p.Cursor != "" {
+		params["cursor"] = p.Cursor
+	} else {
+		params["page"] = strconv.Itoa(p.Page)
+	}
+	return params
+}
+
+// EncodeCursor serializes a keyset position into an opaque cursor string
+func EncodeCursor(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DecodeCursor parses a cursor created by EncodeCursor into v
+func DecodeCursor(cursor string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return fmt.Errorf("invalid cursor")
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("invalid cursor")
+	}
+	return nil
+}
+
+// PageLink returns the request URL with the given query parameters replaced.
+// Parameters set to an empty string are removed.
+func PageLink(c *gin.Context, params map[string]string) string {
+	link := *c.Request.URL
+	query := link.Query()
+	for key, value := range params {
+		if value == "" {
+			query.Del(key)
+		} else {
+			query.Set(key, value)
+		}
+	}
+	link.RawQuery = query.Encode()
+	return (&url.URL{Path: link.Path, RawQuery: link.RawQuery}).String()
+}
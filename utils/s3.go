@@ -1,45 +0,0 @@
-package utils
-
-import (
-	"bytes"
-	"context"
-	"log"
-
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
-)
-
-// Upload file to S3 utility
-func UploadFileToS3(bucketName string, key string, file []byte) error {
-	cfg, err := config.LoadDefaultConfig(context.TODO())
-	if err != nil {
-		log.Fatal(err)
-	}
-	client := s3.NewFromConfig(cfg)
-	_, err = client.PutObject(context.TODO(), &s3.PutObjectInput{
-		Bucket: &bucketName,
-		Key:    &key,
-		Body:   bytes.NewReader(file),
-	})
-	if err != nil {
-		log.Fatal(err)
-	}
-	return nil
-}
-
-// Delete file from S3 utility
-func DeleteFileFromS3(bucketName string, key string) error {
-	cfg, err := config.LoadDefaultConfig(context.TODO())
-	if err != nil {
-		log.Fatal(err)
-	}
-	client := s3.NewFromConfig(cfg)
-	_, err = client.DeleteObject(context.TODO(), &s3.DeleteObjectInput{
-		Bucket: &bucketName,
-		Key:    &key,
-	})
-	if err != nil {
-		log.Fatal(err)
-	}
-	return nil
-}